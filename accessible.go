@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+// In accessible mode, for screen readers, the conversation isn't redrawn:
+// each line is printed above the input once, as it happens, and the view is
+// just the input, with the terminal's own cursor. There's no spinner; what's
+// going on is announced instead.
+
+// announcement returns how r is printed in accessible mode.
+func (r remark) announcement() string {
+	switch {
+	case r.fromEliza():
+		return "ELIZA says: " + r.text
+	case r.kind == kindRename:
+		return r.text + "."
+	case r.status == statusPending:
+		return "Queued until ELIZA replies: " + r.text
+	}
+	return r.speaker + " says: " + r.text
+}
+
+// announce returns a command printing what's happened in the active tab
+// since it was last announced, and marks it announced.
+func (m model) announce() (model, tea.Cmd) {
+	var lines []string
+	if i := slices.IndexFunc(m.remarks, func(r remark) bool { return !r.announced }); i >= 0 {
+		m.remarks = slices.Clone(m.remarks)
+		for j := i; j < len(m.remarks); j++ {
+			if m.remarks[j].announced {
+				continue
+			}
+			lines = append(lines, m.remarks[j].announcement())
+			m.remarks[j].announced = true
+			if m.remarks[j].fromEliza() {
+				// If she's still being waited for, it's for
+				// the next sentence.
+				m.thinkingAnnounced = false
+			}
+		}
+	}
+	if m.notice.text == "" {
+		m.noticeAnnounced = notice{}
+	} else if m.notice != m.noticeAnnounced {
+		lines = append(lines, m.notice.text)
+		m.noticeAnnounced = m.notice
+	}
+	if m.retry != nil {
+		if m.retry != m.retryAnnounced {
+			lines = append(lines, m.retryStatus())
+			m.retryAnnounced = m.retry
+		}
+		// Once the retry's sent, ELIZA is thinking again.
+		m.thinkingAnnounced = false
+	} else if !m.waitingForResponse {
+		m.thinkingAnnounced = false
+	} else if !m.thinkingAnnounced {
+		lines = append(lines, "ELIZA is thinking…")
+		m.thinkingAnnounced = true
+	}
+	if len(lines) == 0 {
+		return m, nil
+	}
+	if len(m.tabs) > 1 {
+		for i, line := range lines {
+			lines[i] = fmt.Sprintf("Tab %d: %s", m.active+1, line)
+		}
+	}
+	return m, tea.Println(strings.Join(lines, "\n"))
+}
+
+// accessibleView renders the input for accessible mode, returning where the
+// terminal's cursor goes.
+func (m model) accessibleView() (string, *tea.Cursor) {
+	var view strings.Builder
+	view.WriteString(m.tabBar())
+	if !m.hasIntroduced {
+		view.WriteString("What's your name?\n")
+	}
+	input := m.textInput
+	input.SetVirtualCursor(false)
+	cursor := input.Cursor()
+	if cursor != nil {
+		cursor.Position.Y += strings.Count(view.String(), "\n")
+	}
+	view.WriteString(input.View())
+	return view.String(), cursor
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
+	tea "charm.land/bubbletea/v2"
+	"connectrpc.com/connect"
+	"github.com/charmbracelet/x/ansi"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp"
+)
+
+// heldRepliesHandler holds each of ELIZA's replies on a Converse stream
+// until it's released.
+type heldRepliesHandler struct {
+	*fakeElizaServiceHandler
+
+	release chan struct{}
+}
+
+func (h *heldRepliesHandler) Converse(
+	ctx context.Context,
+	stream *connect.BidiStream[elizav1.ConverseRequest, elizav1.ConverseResponse],
+) error {
+	for {
+		req, err := stream.Receive()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		select {
+		case <-h.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := stream.Send(&elizav1.ConverseResponse{
+			Sentence: fmt.Sprintf("I see. You said: %q. Tell me more.", req.Sentence),
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+// printedLines reports whether lines were printed in output, in order, with
+// nothing but other lines and redrawn input between them.
+func printedLines(output string, lines ...string) bool {
+	for _, line := range lines {
+		i := strings.Index(output, "\n"+line+"\r\n")
+		if i < 0 {
+			return false
+		}
+		output = output[i+len(line)+1:]
+	}
+	return true
+}
+
+func TestAccessibleModePrintsEachLineOnce(t *testing.T) {
+	t.Parallel()
+
+	handler := &heldRepliesHandler{
+		fakeElizaServiceHandler: &fakeElizaServiceHandler{},
+		release:                 make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.Handle(elizav1connect.NewElizaServiceHandler(handler))
+	server, err := memhttp.New(mux)
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() {
+		attest.Ok(t, server.Close())
+	})
+	m := initialModel(elizav1connect.NewElizaServiceClient(server.Client(), server.URL()))
+	m.accessible = true
+	attest.Zero(t, m.Init())
+	view := m.View()
+	attest.True(t, strings.HasPrefix(ansi.Strip(view.Content), "What's your name?\n> "))
+	attest.True(t, view.Cursor != nil, attest.Fatal())
+	attest.Equal(t, view.Cursor.Position.Y, 1)
+
+	h := startTUI(t, m)
+	enter := func(text string) {
+		h.typeText(text)
+		h.press(tea.KeyEnter)
+	}
+	waitFor := func(lines ...string) {
+		t.Helper()
+		h.waitForOutput(strings.Join(lines, ", "), func(output string) bool {
+			return printedLines(output, lines...)
+		})
+	}
+
+	enter("Alice")
+	waitFor(
+		"ELIZA is thinking…",
+		"ELIZA says: Hello Alice, I'm ELIZA.",
+		"ELIZA says: How are you feeling today?",
+		"ELIZA says: I'm here to help you.",
+	)
+	enter("hello")
+	waitFor("Alice says: hello", "ELIZA is thinking…")
+	enter("again")
+	waitFor("Queued until ELIZA replies: again")
+	handler.release <- struct{}{}
+	waitFor(`ELIZA says: I see. You said: "hello". Tell me more.`, "ELIZA is thinking…")
+	handler.release <- struct{}{}
+	waitFor(`ELIZA says: I see. You said: "again". Tell me more.`)
+
+	// A resize redraws only the input.
+	h.program.Send(tea.WindowSizeMsg{Width: 60, Height: 20})
+
+	// Search and selection are off, since the conversation isn't drawn.
+	h.program.Send(tea.KeyPressMsg{Code: 's', Mod: tea.ModCtrl})
+	enter("/search cat")
+	output := h.waitForOutput("search to be turned down", func(output string) bool {
+		return printedLines(output, "Search is off in accessible mode: use the terminal's scrollback.")
+	})
+
+	// Each line was printed once, in order, and the view is only ever
+	// the input.
+	attest.True(t, printedLines(output,
+		"ELIZA is thinking…",
+		"ELIZA says: Hello Alice, I'm ELIZA.",
+		"ELIZA says: How are you feeling today?",
+		"ELIZA says: I'm here to help you.",
+		"Alice says: hello",
+		"ELIZA is thinking…",
+		"Queued until ELIZA replies: again",
+		`ELIZA says: I see. You said: "hello". Tell me more.`,
+		"ELIZA is thinking…",
+		`ELIZA says: I see. You said: "again". Tell me more.`,
+		"Search is off in accessible mode: use the terminal's scrollback.",
+	), attest.Sprintf("output:\n%s", output))
+	for _, line := range []string{
+		"I'm here to help you.",
+		`You said: "hello".`,
+		`You said: "again".`,
+		"again\r\n",
+	} {
+		attest.Equal(t, strings.Count(output, line), 1, attest.Sprintf("printed %q", line))
+	}
+	attest.Equal(t, strings.TrimSpace(h.frames.last()), ">")
+	h.quit()
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
+	"connectrpc.com/connect"
+	"go.akshayshah.org/memhttp"
+)
+
+// defaultCorpus is sent when bench isn't given a corpus file.
+var defaultCorpus = []string{
+	"Hello.",
+	"I feel a bit anxious today.",
+	"My mother never listens to me.",
+	"I remember when things were simpler.",
+	"Computers make me nervous.",
+	"Why do you ask so many questions?",
+	"Everyone is against me.",
+	"I want a holiday.",
+}
+
+// benchConfig configures a load test.
+type benchConfig struct {
+	mode     string // "converse" or "say"
+	workers  int
+	rate     float64 // messages per second per worker; zero is unlimited
+	duration time.Duration
+	corpus   []string
+}
+
+// benchResult is the outcome of a load test.
+type benchResult struct {
+	Mode       string         `json:"mode"`
+	Workers    int            `json:"workers"`
+	Duration   time.Duration  `json:"duration"`
+	Requests   int            `json:"requests"`
+	Errors     map[string]int `json:"errors"`
+	Throughput float64        `json:"throughput"` // successful requests per second
+	Latency    percentiles    `json:"latency"`
+	Setup      *percentiles   `json:"setup,omitempty"` // Converse stream setup
+	latencies  []time.Duration
+	setups     []time.Duration
+	mu         sync.Mutex
+}
+
+type percentiles struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+}
+
+func runBench(args []string) (err error) {
+	var (
+		opts   commonOptions
+		cfg    benchConfig
+		url    string
+		local  bool
+		corpus string
+		format string
+	)
+	fs := flag.NewFlagSet("eliza bench", flag.ExitOnError)
+	opts.register(fs)
+	fs.StringVar(&url, "url", defaultURL, "base `URL` of the ELIZA service")
+	fs.BoolVar(&local, "local", false, "benchmark an in-process eliza serve handler instead of -url")
+	fs.StringVar(&cfg.mode, "mode", "converse", "RPC to exercise: converse or say")
+	fs.IntVar(&cfg.workers, "streams", 10, "number of concurrent streams (or callers, with -mode say)")
+	fs.Float64Var(&cfg.rate, "rate", 0, "messages per second per stream; 0 sends as fast as possible")
+	fs.DurationVar(&cfg.duration, "duration", 10*time.Second, "how long to run")
+	fs.StringVar(&corpus, "corpus", "", "file of sentences to send, one per line")
+	fs.StringVar(&format, "format", "text", "report `format`: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if cfg.mode != "converse" && cfg.mode != "say" {
+		return fmt.Errorf("invalid mode %q: must be converse or say", cfg.mode)
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid format %q: must be text or json", format)
+	}
+	if cfg.workers < 1 {
+		return errors.New("-streams must be at least 1")
+	}
+	cfg.corpus = defaultCorpus
+	if corpus != "" {
+		if cfg.corpus, err = readCorpus(corpus); err != nil {
+			return err
+		}
+	}
+
+	interceptors, cleanup, err := opts.setup()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, cleanup())
+	}()
+
+	var client elizav1connect.ElizaServiceClient
+	if local {
+		server, err := memhttp.New(newServeMux(nil, connect.WithInterceptors(interceptors...)))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = errors.Join(err, server.Close())
+		}()
+		client = elizav1connect.NewElizaServiceClient(server.Client(), server.URL())
+	} else {
+		var closeClient func() error
+		client, closeClient, err = newRemoteClient(url, connect.WithInterceptors(interceptors...))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = errors.Join(err, closeClient())
+		}()
+	}
+
+	result := bench(context.Background(), client, cfg)
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	return result.writeText(os.Stdout)
+}
+
+func readCorpus(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var sentences []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			sentences = append(sentences, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(sentences) == 0 {
+		return nil, fmt.Errorf("corpus %s is empty", path)
+	}
+	return sentences, nil
+}
+
+// bench runs a load test against client.
+func bench(ctx context.Context, client elizav1connect.ElizaServiceClient, cfg benchConfig) *benchResult {
+	ctx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+
+	result := &benchResult{
+		Mode:    cfg.mode,
+		Workers: cfg.workers,
+		Errors:  make(map[string]int),
+	}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for worker := range cfg.workers {
+		wg.Go(func() {
+			w := &benchWorker{
+				client: client,
+				cfg:    cfg,
+				result: result,
+				// Stagger the corpus so workers don't all send the same
+				// sentence.
+				next: worker,
+			}
+			if cfg.mode == "say" {
+				w.say(ctx)
+			} else {
+				w.converse(ctx)
+			}
+		})
+	}
+	wg.Wait()
+	result.Duration = time.Since(start)
+	result.summarize()
+	return result
+}
+
+type benchWorker struct {
+	client elizav1connect.ElizaServiceClient
+	cfg    benchConfig
+	result *benchResult
+	next   int
+}
+
+func (w *benchWorker) sentence() string {
+	sentence := w.cfg.corpus[w.next%len(w.cfg.corpus)]
+	w.next++
+	return sentence
+}
+
+// wait paces the worker to the configured rate. It returns false when the
+// test is over.
+func (w *benchWorker) wait(ctx context.Context, ticker *time.Ticker) bool {
+	if ticker == nil {
+		return ctx.Err() == nil
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-ticker.C:
+		return true
+	}
+}
+
+func (w *benchWorker) ticker() *time.Ticker {
+	if w.cfg.rate <= 0 {
+		return nil
+	}
+	return time.NewTicker(time.Duration(float64(time.Second) / w.cfg.rate))
+}
+
+func (w *benchWorker) say(ctx context.Context) {
+	ticker := w.ticker()
+	if ticker != nil {
+		defer ticker.Stop()
+	}
+	for w.wait(ctx, ticker) {
+		start := time.Now()
+		_, err := w.client.Say(ctx, connect.NewRequest(&elizav1.SayRequest{
+			Sentence: w.sentence(),
+		}))
+		w.record(ctx, time.Since(start), err)
+	}
+}
+
+func (w *benchWorker) converse(ctx context.Context) {
+	ticker := w.ticker()
+	if ticker != nil {
+		defer ticker.Stop()
+	}
+	var stream *connect.BidiStreamForClient[elizav1.ConverseRequest, elizav1.ConverseResponse]
+	closeStream := func() {
+		if stream != nil {
+			_ = stream.CloseRequest()
+			_ = stream.CloseResponse()
+			stream = nil
+		}
+	}
+	defer closeStream()
+	// backoff is how long to wait before opening another stream, after
+	// the last one failed. It doubles with each failure in a row, so a
+	// server that's down isn't sent streams in a busy loop.
+	var backoff time.Duration
+	for w.wait(ctx, ticker) {
+		if stream == nil && backoff > 0 && waitUntil(ctx, time.Now().Add(backoff)) != nil {
+			return
+		}
+		var setupStart time.Time
+		if stream == nil {
+			setupStart = time.Now()
+			stream = w.client.Converse(ctx)
+			// Sending just the headers starts the request.
+			if err := stream.Send(nil); err != nil {
+				w.record(ctx, 0, err)
+				closeStream()
+				backoff = nextBenchBackoff(backoff)
+				continue
+			}
+		}
+		start := time.Now()
+		err := stream.Send(&elizav1.ConverseRequest{Sentence: w.sentence()})
+		if err == nil && !setupStart.IsZero() {
+			// The stream is set up once the server's headers are
+			// back. Servers that hold them until the first reply
+			// would stall a wait before any sentence is sent.
+			stream.ResponseHeader()
+			w.result.mu.Lock()
+			w.result.setups = append(w.result.setups, time.Since(setupStart))
+			w.result.mu.Unlock()
+		}
+		if err == nil {
+			_, err = stream.Receive()
+		} else if errors.Is(err, io.EOF) {
+			// The server closed the stream; Receive has the real error.
+			_, err = stream.Receive()
+		}
+		w.record(ctx, time.Since(start), err)
+		if err != nil {
+			// Open a fresh stream for the next message.
+			closeStream()
+			backoff = nextBenchBackoff(backoff)
+		} else {
+			backoff = 0
+		}
+	}
+}
+
+// nextBenchBackoff returns how long to wait before opening a stream, after
+// one more failure than backoff was for.
+func nextBenchBackoff(backoff time.Duration) time.Duration {
+	return min(max(2*backoff, 10*time.Millisecond), time.Second)
+}
+
+// record adds the outcome of one request to the result. Errors caused by the
+// end of the test are ignored.
+func (w *benchWorker) record(ctx context.Context, latency time.Duration, err error) {
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	w.result.mu.Lock()
+	defer w.result.mu.Unlock()
+	w.result.Requests++
+	if err != nil {
+		w.result.Errors[connect.CodeOf(err).String()]++
+		return
+	}
+	w.result.latencies = append(w.result.latencies, latency)
+}
+
+func (r *benchResult) summarize() {
+	if r.Duration > 0 {
+		r.Throughput = float64(len(r.latencies)) / r.Duration.Seconds()
+	}
+	r.Latency = percentilesOf(r.latencies)
+	if r.Mode == "converse" {
+		setup := percentilesOf(r.setups)
+		r.Setup = &setup
+	}
+}
+
+// percentilesOf returns the nearest-rank percentiles of durations.
+func percentilesOf(durations []time.Duration) percentiles {
+	if len(durations) == 0 {
+		return percentiles{}
+	}
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	rank := func(p float64) time.Duration {
+		i := int(p*float64(len(sorted))+0.5) - 1
+		return sorted[max(0, min(i, len(sorted)-1))]
+	}
+	return percentiles{P50: rank(0.50), P90: rank(0.90), P99: rank(0.99)}
+}
+
+func (r *benchResult) writeText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "mode:         %s\n", r.Mode)
+	fmt.Fprintf(&b, "streams:      %d\n", r.Workers)
+	fmt.Fprintf(&b, "duration:     %s\n", r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(&b, "requests:     %d (%d errors)\n", r.Requests, r.Requests-len(r.latencies))
+	fmt.Fprintf(&b, "throughput:   %.1f req/s\n", r.Throughput)
+	fmt.Fprintf(&b, "latency:      %s\n", r.Latency)
+	if r.Setup != nil {
+		fmt.Fprintf(&b, "stream setup: %s\n", r.Setup)
+	}
+	if len(r.Errors) > 0 {
+		b.WriteString("errors:\n")
+		for _, code := range slices.Sorted(maps.Keys(r.Errors)) {
+			fmt.Fprintf(&b, "  %-20s %d\n", code+":", r.Errors[code])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (p percentiles) String() string {
+	return fmt.Sprintf("p50=%s p90=%s p99=%s",
+		p.P50.Round(time.Microsecond),
+		p.P90.Round(time.Microsecond),
+		p.P99.Round(time.Microsecond),
+	)
+}
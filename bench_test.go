@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestBenchLocal(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []string{"converse", "say"} {
+		t.Run(mode, func(t *testing.T) {
+			t.Parallel()
+
+			result := bench(t.Context(), startLocalServer(t), benchConfig{
+				mode:     mode,
+				workers:  3,
+				rate:     100,
+				duration: 200 * time.Millisecond,
+				corpus:   defaultCorpus,
+			})
+			attest.True(t, result.Requests > 0)
+			attest.Equal(t, len(result.Errors), 0)
+			attest.True(t, result.Throughput > 0)
+			attest.True(t, result.Latency.P50 > 0)
+			attest.True(t, result.Latency.P50 <= result.Latency.P99)
+			if mode == "converse" {
+				attest.Equal(t, len(result.setups), 3)
+			}
+
+			var report strings.Builder
+			attest.Ok(t, result.writeText(&report))
+			attest.Subsequence(t, report.String(), "latency:      p50=")
+		})
+	}
+}
+
+func TestBenchCountsErrorCodes(t *testing.T) {
+	t.Parallel()
+
+	result := bench(t.Context(), startFakeServerWithErrors(t), benchConfig{
+		mode:     "say",
+		workers:  1,
+		rate:     100,
+		duration: 100 * time.Millisecond,
+		corpus:   defaultCorpus,
+	})
+	attest.True(t, result.Errors["unknown"] > 0)
+	attest.Equal(t, result.Errors["unknown"], result.Requests)
+	attest.Equal(t, result.Throughput, 0.0)
+}
+
+func TestBenchBacksOffFailingStreams(t *testing.T) {
+	t.Parallel()
+
+	// Without a rate, only backing off stops the worker opening streams
+	// in a busy loop.
+	result := bench(t.Context(), startFakeServerWithErrors(t), benchConfig{
+		mode:     "converse",
+		workers:  1,
+		duration: 300 * time.Millisecond,
+		corpus:   defaultCorpus,
+	})
+	attest.True(t, result.Errors["unknown"] > 0)
+	attest.True(t, result.Requests <= 10, attest.Sprintf("%d streams failed", result.Requests))
+}
+
+func TestPercentilesOf(t *testing.T) {
+	t.Parallel()
+
+	var durations []time.Duration
+	for i := 100; i > 0; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	attest.Equal(t, percentilesOf(durations), percentiles{
+		P50: 50 * time.Millisecond,
+		P90: 90 * time.Millisecond,
+		P99: 99 * time.Millisecond,
+	})
+	attest.Equal(t, percentilesOf(nil), percentiles{})
+}
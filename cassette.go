@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
+	"connectrpc.com/connect"
+	"go.akshayshah.org/memhttp"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// A cassette is a recording of the RPCs made to an ELIZA service, which can
+// be replayed later without network access.
+type cassette struct {
+	Interactions []*interaction `json:"interactions"`
+}
+
+// An interaction is a single recorded RPC.
+type interaction struct {
+	Procedure       string         `json:"procedure"`
+	RequestHeader   http.Header    `json:"requestHeader,omitempty"`
+	ResponseHeader  http.Header    `json:"responseHeader,omitempty"`
+	ResponseTrailer http.Header    `json:"responseTrailer,omitempty"`
+	Events          []*event       `json:"events"`
+	Error           *recordedError `json:"error,omitempty"`
+}
+
+// An event is a message sent or received by the client, and when it happened
+// relative to the start of the RPC.
+type event struct {
+	Direction string          `json:"direction"` // "send" or "receive"
+	Offset    time.Duration   `json:"offset"`
+	Message   json.RawMessage `json:"message"`
+}
+
+type recordedError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func newRecordedError(err error) *recordedError {
+	message := err.Error()
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		message = connectErr.Message()
+	}
+	return &recordedError{
+		Code:    connect.CodeOf(err).String(),
+		Message: message,
+	}
+}
+
+func (e *recordedError) err() error {
+	var code connect.Code
+	if err := code.UnmarshalText([]byte(e.Code)); err != nil {
+		code = connect.CodeUnknown
+	}
+	return connect.NewError(code, errors.New(e.Message))
+}
+
+func loadCassette(path string) (*cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// recorder is a client interceptor that records every RPC into a cassette.
+type recorder struct {
+	mu       sync.Mutex
+	cassette cassette
+}
+
+// save writes the interactions recorded so far to path.
+func (r *recorder) save(path string) error {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(&r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func (r *recorder) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if !req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		rec := &interaction{Procedure: req.Spec().Procedure}
+		r.mu.Lock()
+		r.cassette.Interactions = append(r.cassette.Interactions, rec)
+		r.mu.Unlock()
+		start := time.Now()
+		r.record(rec, start, "send", req.Any())
+		res, err := next(ctx, req)
+		if err == nil {
+			r.record(rec, start, "receive", res.Any())
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		rec.RequestHeader = req.Header().Clone()
+		if err != nil {
+			rec.Error = newRecordedError(err)
+			return nil, err
+		}
+		rec.ResponseHeader = res.Header().Clone()
+		rec.ResponseTrailer = res.Trailer().Clone()
+		return res, nil
+	}
+}
+
+// record adds msg to rec, as sent or received at the current offset from
+// start.
+func (r *recorder) record(rec *interaction, start time.Time, direction string, msg any) {
+	message, ok := msg.(proto.Message)
+	if !ok {
+		return
+	}
+	data, err := protojson.Marshal(message)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec.Events = append(rec.Events, &event{
+		Direction: direction,
+		Offset:    time.Since(start),
+		Message:   data,
+	})
+}
+
+func (r *recorder) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		rec := &interaction{Procedure: spec.Procedure}
+		r.mu.Lock()
+		r.cassette.Interactions = append(r.cassette.Interactions, rec)
+		r.mu.Unlock()
+		return &recordingClientConn{
+			StreamingClientConn: next(ctx, spec),
+			recorder:            r,
+			interaction:         rec,
+			start:               time.Now(),
+		}
+	}
+}
+
+func (r *recorder) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+type recordingClientConn struct {
+	connect.StreamingClientConn
+
+	recorder    *recorder
+	interaction *interaction
+	start       time.Time
+}
+
+func (c *recordingClientConn) Send(msg any) error {
+	c.record("send", msg)
+	err := c.StreamingClientConn.Send(msg)
+	c.recordErr(err)
+	return err
+}
+
+func (c *recordingClientConn) Receive(msg any) error {
+	err := c.StreamingClientConn.Receive(msg)
+	if err == nil {
+		c.record("receive", msg)
+	}
+	c.recordErr(err)
+	return err
+}
+
+func (c *recordingClientConn) CloseResponse() error {
+	err := c.StreamingClientConn.CloseResponse()
+	c.recorder.mu.Lock()
+	defer c.recorder.mu.Unlock()
+	c.interaction.RequestHeader = c.RequestHeader().Clone()
+	c.interaction.ResponseHeader = c.ResponseHeader().Clone()
+	c.interaction.ResponseTrailer = c.ResponseTrailer().Clone()
+	return err
+}
+
+func (c *recordingClientConn) record(direction string, msg any) {
+	c.recorder.record(c.interaction, c.start, direction, msg)
+}
+
+// recordErr records the first error, other than end of stream, on the RPC.
+func (c *recordingClientConn) recordErr(err error) {
+	if err == nil || errors.Is(err, io.EOF) {
+		return
+	}
+	c.recorder.mu.Lock()
+	defer c.recorder.mu.Unlock()
+	if c.interaction.Error != nil {
+		return
+	}
+	c.interaction.Error = newRecordedError(err)
+}
+
+// replayHandler serves the interactions in a cassette, in the order they were
+// recorded, with the same messages, headers, errors and timing.
+type replayHandler struct {
+	elizav1connect.UnimplementedElizaServiceHandler
+
+	mu           sync.Mutex
+	interactions []*interaction
+}
+
+func newReplayHandler(c *cassette) *replayHandler {
+	return &replayHandler{interactions: c.Interactions}
+}
+
+// next removes and returns the next recorded interaction for procedure.
+func (h *replayHandler) next(procedure string) (*interaction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, rec := range h.interactions {
+		if rec.Procedure == procedure {
+			h.interactions = append(h.interactions[:i:i], h.interactions[i+1:]...)
+			return rec, nil
+		}
+	}
+	return nil, connect.NewError(
+		connect.CodeFailedPrecondition,
+		fmt.Errorf("cassette has no more %s interactions", procedure),
+	)
+}
+
+func (h *replayHandler) Say(
+	ctx context.Context,
+	req *connect.Request[elizav1.SayRequest],
+) (*connect.Response[elizav1.SayResponse], error) {
+	rec, err := h.next(elizav1connect.ElizaServiceSayProcedure)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	for _, ev := range rec.Events {
+		if ev.Direction != "receive" {
+			continue
+		}
+		if err := waitUntil(ctx, start.Add(ev.Offset)); err != nil {
+			return nil, err
+		}
+		res := connect.NewResponse(&elizav1.SayResponse{})
+		if err := protojson.Unmarshal(ev.Message, res.Msg); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+		copyHeaders(res.Header(), rec.ResponseHeader)
+		copyHeaders(res.Trailer(), rec.ResponseTrailer)
+		return res, nil
+	}
+	if rec.Error != nil {
+		return nil, rec.Error.err()
+	}
+	return nil, connect.NewError(connect.CodeDataLoss, errors.New("cassette has no reply to Say"))
+}
+
+func (h *replayHandler) Introduce(
+	ctx context.Context,
+	req *connect.Request[elizav1.IntroduceRequest],
+	stream *connect.ServerStream[elizav1.IntroduceResponse],
+) error {
+	rec, err := h.next(elizav1connect.ElizaServiceIntroduceProcedure)
+	if err != nil {
+		return err
+	}
+	copyHeaders(stream.ResponseHeader(), rec.ResponseHeader)
+	copyHeaders(stream.ResponseTrailer(), rec.ResponseTrailer)
+	start := time.Now()
+	for _, ev := range rec.Events {
+		if ev.Direction != "receive" {
+			continue
+		}
+		if err := waitUntil(ctx, start.Add(ev.Offset)); err != nil {
+			return err
+		}
+		res := &elizav1.IntroduceResponse{}
+		if err := protojson.Unmarshal(ev.Message, res); err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+	if rec.Error != nil {
+		return rec.Error.err()
+	}
+	return nil
+}
+
+func (h *replayHandler) Converse(
+	ctx context.Context,
+	stream *connect.BidiStream[elizav1.ConverseRequest, elizav1.ConverseResponse],
+) error {
+	rec, err := h.next(elizav1connect.ElizaServiceConverseProcedure)
+	if err != nil {
+		return err
+	}
+	copyHeaders(stream.ResponseHeader(), rec.ResponseHeader)
+	copyHeaders(stream.ResponseTrailer(), rec.ResponseTrailer)
+	start := time.Now()
+	for _, ev := range rec.Events {
+		switch ev.Direction {
+		case "send":
+			// Wait for the client's next message. Its content isn't
+			// checked: the cassette's replies are played back regardless.
+			if _, err := stream.Receive(); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+			// Replies are timed relative to the request that prompted
+			// them, not to the start of the stream.
+			start = time.Now().Add(-ev.Offset)
+		case "receive":
+			if err := waitUntil(ctx, start.Add(ev.Offset)); err != nil {
+				return err
+			}
+			res := &elizav1.ConverseResponse{}
+			if err := protojson.Unmarshal(ev.Message, res); err != nil {
+				return connect.NewError(connect.CodeInternal, err)
+			}
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+		}
+	}
+	if rec.Error != nil {
+		return rec.Error.err()
+	}
+	// Drain the client's side of the stream until it closes.
+	for {
+		if _, err := stream.Receive(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// newReplayServer starts an in-memory server replaying c, and returns a
+// client for it. The returned function stops the server.
+func newReplayServer(c *cassette, opts ...connect.ClientOption) (elizav1connect.ElizaServiceClient, func() error, error) {
+	mux := http.NewServeMux()
+	mux.Handle(elizav1connect.NewElizaServiceHandler(newReplayHandler(c)))
+	server, err := memhttp.New(mux)
+	if err != nil {
+		return nil, nil, err
+	}
+	return elizav1connect.NewElizaServiceClient(server.Client(), server.URL(), opts...), server.Close, nil
+}
+
+// copyHeaders copies recorded headers into dst, skipping those managed by
+// the protocol itself.
+func copyHeaders(dst, src http.Header) {
+	for key, values := range src {
+		switch {
+		case strings.HasPrefix(key, "Content-"),
+			strings.HasPrefix(key, "Connect-"),
+			strings.HasPrefix(key, "Grpc-"),
+			key == "Date",
+			key == "Trailer",
+			key == "Accept-Encoding":
+			continue
+		}
+		dst[key] = append(dst[key], values...)
+	}
+}
+
+func waitUntil(ctx context.Context, deadline time.Time) error {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp"
+)
+
+// startRecordingServer starts an in-memory server for handler and returns a
+// client that records into rec.
+func startRecordingServer(t *testing.T, handler elizav1connect.ElizaServiceHandler, rec *recorder) elizav1connect.ElizaServiceClient {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.Handle(elizav1connect.NewElizaServiceHandler(handler))
+	server, err := memhttp.New(mux)
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() {
+		attest.Ok(t, server.Close())
+	})
+	return elizav1connect.NewElizaServiceClient(
+		server.Client(),
+		server.URL(),
+		connect.WithInterceptors(rec),
+	)
+}
+
+// startReplayServer saves rec to a cassette, then returns a client replaying
+// it.
+func startReplayServer(t *testing.T, rec *recorder) elizav1connect.ElizaServiceClient {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	attest.Ok(t, rec.save(path), attest.Fatal())
+	c, err := loadCassette(path)
+	attest.Ok(t, err, attest.Fatal())
+	client, stop, err := newReplayServer(c)
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() {
+		attest.Ok(t, stop())
+	})
+	return client
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	t.Parallel()
+
+	rec := &recorder{}
+	recorded := initialModel(startRecordingServer(t, &fakeElizaServiceHandler{}, rec))
+	introduction := recorded.introduce("User")()
+	recorded.hasIntroduced = true
+	recorded.name = "User"
+	recorded = sendMessage(t, recorded, "hello")
+	recorded = sendMessage(t, recorded, "goodbye")
+	recorded.closeConversations()
+	recorded.waitForConversations()
+
+	attest.Equal(t, len(rec.cassette.Interactions), 2)
+	attest.Equal(t, rec.cassette.Interactions[0].Procedure, elizav1connect.ElizaServiceIntroduceProcedure)
+	attest.Equal(t, rec.cassette.Interactions[1].Procedure, elizav1connect.ElizaServiceConverseProcedure)
+	attest.Equal(t, len(rec.cassette.Interactions[1].Events), 4)
+	attest.NotZero(t, rec.cassette.Interactions[1].ResponseHeader)
+
+	replayed := initialModel(startReplayServer(t, rec))
+	attest.Equal(t, replayed.introduce("Someone else")(), introduction)
+	replayed.hasIntroduced = true
+	replayed.name = "User"
+	// The replayed replies don't depend on what's sent.
+	replayed = sendMessage(t, replayed, "something")
+	replayed = sendMessage(t, replayed, "else")
+	replayed.closeConversations()
+	replayed.waitForConversations()
+	attest.Equal(t, replies(replayed), replies(recorded))
+}
+
+func TestRecordAndReplaySay(t *testing.T) {
+	t.Parallel()
+
+	rec := &recorder{}
+	recorded := introduceTab(t, initialModel(startRecordingServer(t, &fakeElizaServiceHandler{}, rec)), "User")
+	recorded = runSlash(t, recorded, "/mode unary")
+	recorded = sendMessage(t, recorded, "hello")
+
+	attest.Equal(t, len(rec.cassette.Interactions), 2)
+	say := rec.cassette.Interactions[1]
+	attest.Equal(t, say.Procedure, elizav1connect.ElizaServiceSayProcedure)
+	attest.Equal(t, len(say.Events), 2)
+	attest.NotZero(t, say.RequestHeader)
+
+	replayed := introduceTab(t, initialModel(startReplayServer(t, rec)), "User")
+	replayed = runSlash(t, replayed, "/mode unary")
+	replayed = sendMessage(t, replayed, "something else")
+	attest.Equal(t, replies(replayed), replies(recorded))
+}
+
+func TestReplayErrors(t *testing.T) {
+	t.Parallel()
+
+	rec := &recorder{}
+	recorded := initialModel(startRecordingServer(t, &fakeElizaServiceErrorHandler{}, rec))
+	recordedErr, ok := recorded.introduce("User")().(errMsg)
+	attest.True(t, ok, attest.Fatal())
+
+	replayed := initialModel(startReplayServer(t, rec))
+	replayedErr, ok := replayed.introduce("User")().(errMsg)
+	attest.True(t, ok, attest.Fatal())
+	attest.Equal(t, connect.CodeOf(replayedErr), connect.CodeOf(recordedErr))
+	attest.Equal(t, replayedErr.Error(), recordedErr.Error())
+
+	// The cassette is exhausted.
+	_, ok = replayed.introduce("User")().(errMsg)
+	attest.True(t, ok)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"slices"
+
+	"charm.land/bubbles/v2/key"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/atotto/clipboard"
+)
+
+var selectedStyle = lipgloss.NewStyle().Reverse(true)
+
+// localClipboard returns a function that copies text with a local clipboard
+// utility, such as pbcopy, xclip or wl-copy, or nil if there isn't one. Over
+// SSH, the local clipboard belongs to the wrong machine, so it's left to
+// OSC 52 to reach the user's terminal.
+func localClipboard() func(text string) error {
+	if clipboard.Unsupported || os.Getenv("SSH_CONNECTION") != "" {
+		return nil
+	}
+	return clipboard.WriteAll
+}
+
+// copyText copies text to the clipboard, describing it as what. It's sent to
+// the terminal with OSC 52, which works over SSH, and to the local clipboard
+// utility in case the terminal doesn't support OSC 52.
+func (m model) copyText(text, what string) tea.Cmd {
+	local := m.clipboard
+	return tea.Batch(tea.SetClipboard(text), m.forSession(func() tea.Msg {
+		if local != nil {
+			if err := local(text); err != nil {
+				slog.Warn("copying with the local clipboard utility", errorAttrs(err)...)
+			}
+		}
+		return noticeMsg(info("Copied %s to the clipboard.", what))
+	}))
+}
+
+// lastReply returns ELIZA's last reply, if there's been one.
+func (s session) lastReply() (string, bool) {
+	for _, r := range slices.Backward(s.remarks) {
+		if r.fromEliza() {
+			return r.text, true
+		}
+	}
+	return "", false
+}
+
+// startSelecting selects the last message, so it can be copied.
+func (m model) startSelecting() model {
+	entries := m.entries()
+	m.selected = len(entries)
+	if i := m.selectable(entries, -1); i >= 0 {
+		m.search.active = false
+		m.selecting = true
+		m.selected = i
+		m.textInput.Blur()
+		return m.showSelected()
+	}
+	return m
+}
+
+func (m model) stopSelecting() model {
+	m.selecting = false
+	m.textInput.Focus()
+	return m
+}
+
+// selectable returns the index of the next message from the selected one in
+// direction, or -1 if there isn't one.
+func (m model) selectable(entries []entry, direction int) int {
+	for i := m.selected + direction; i >= 0 && i < len(entries); i += direction {
+		if entries[i].speaker != "" && !entries[i].thinking {
+			return i
+		}
+	}
+	return -1
+}
+
+// updateSelection handles a key press while selecting. It reports whether
+// the key was for the selection; if not, it's handled as usual.
+func (m model) updateSelection(msg tea.KeyPressMsg) (model, tea.Cmd, bool) {
+	entries := m.entries()
+	switch {
+	case msg.String() == "esc" || key.Matches(msg, m.keys.selectMode):
+		return m.stopSelecting(), nil, true
+	case key.Matches(msg, m.keys.selectPrev), key.Matches(msg, m.keys.selectNext):
+		direction := 1
+		if key.Matches(msg, m.keys.selectPrev) {
+			direction = -1
+		}
+		if i := m.selectable(entries, direction); i >= 0 {
+			m.selected = i
+		}
+		return m.showSelected(), nil, true
+	case key.Matches(msg, m.keys.copy):
+		if m.selected < 0 || m.selected >= len(entries) {
+			// The conversation was cleared.
+			return m.stopSelecting(), nil, true
+		}
+		return m, m.copyText(entries[m.selected].text, "the message"), true
+	case key.Matches(msg, m.keys.copyAll):
+		return m, m.copyText(m.transcriptText(), "the transcript"), true
+	case key.Matches(msg, m.keys.editQueued), key.Matches(msg, m.keys.dropQueued):
+		q := m.selectedPending(entries)
+		if q < 0 {
+			m.notice = failure("Only sentences that haven't been sent yet can be changed.")
+			return m, nil, true
+		}
+		if key.Matches(msg, m.keys.dropQueued) {
+			return m.dropQueued(q), nil, true
+		}
+		if m.textInput.Value() != "" {
+			m.notice = failure("Send or clear what you're typing first.")
+			return m, nil, true
+		}
+		// Editing takes the sentence out of the queue: sending it
+		// again queues it at the end.
+		m.textInput.SetValue(m.remarks[q].text)
+		m.remarks = slices.Delete(slices.Clone(m.remarks), q, q+1)
+		return m.stopSelecting(), nil, true
+	}
+	return m, nil, false
+}
+
+// dropQueued cancels the queued sentence at index q in the log. It stays in
+// the log, and selected, where it was typed.
+func (m model) dropQueued(q int) model {
+	m.setStatus(q, statusCancelled, "")
+	m.notice = info("Cancelled the queued sentence.")
+	m.selected = m.entryOf(m.entries(), q)
+	return m.showSelected()
+}
+
+// selectedPending returns the index in the log of the selected sentence, if
+// it's queued, or -1.
+func (m model) selectedPending(entries []entry) int {
+	if m.selected < 0 || m.selected >= len(entries) || entries[m.selected].status != statusPending {
+		return -1
+	}
+	return entries[m.selected].remark
+}
+
+// entryOf returns the index in entries of the remark at index i in the log,
+// or -1 if it isn't shown.
+func (m model) entryOf(entries []entry, i int) int {
+	return slices.IndexFunc(entries, func(e entry) bool {
+		return e.remark == i && !e.separator
+	})
+}
+
+// showSelected scrolls the conversation to the selected message.
+func (m model) showSelected() model {
+	h := m.historyRows(m.entries(), nil)
+	if m.selected >= len(h.entryRows) {
+		return m
+	}
+	return m.scrollTo(h.entryRows[m.selected], len(h.rows))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"go.akshayshah.org/attest"
+)
+
+// fakeClipboard records what's copied to the local clipboard.
+type fakeClipboard struct {
+	mu     sync.Mutex
+	copied []string
+}
+
+func (c *fakeClipboard) write(text string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.copied = append(c.copied, text)
+	return nil
+}
+
+// runCopy runs the commands cmd batches, applies the resulting notice to m,
+// and returns what was sent to the terminal with OSC 52.
+func runCopy(t *testing.T, m model, cmd tea.Cmd) (model, string) {
+	t.Helper()
+
+	attest.True(t, cmd != nil, attest.Fatal())
+	batch, ok := cmd().(tea.BatchMsg)
+	attest.True(t, ok, attest.Fatal())
+	var osc52 string
+	for _, c := range batch {
+		switch msg := c().(type) {
+		case sessionMsg:
+			next, _ := m.Update(msg)
+			m = next.(model)
+		default:
+			osc52 = fmt.Sprint(msg)
+		}
+	}
+	return m, osc52
+}
+
+func TestSelectAndCopy(t *testing.T) {
+	t.Parallel()
+
+	local := &fakeClipboard{}
+	m := longConversation(t)
+	m.clipboard = local.write
+
+	next, _ := m.Update(tea.KeyPressMsg{Code: 's', Mod: tea.ModCtrl})
+	m = next.(model)
+	attest.True(t, m.selecting)
+	attest.Equal(t, m.entries()[m.selected].text, "Reply 19")
+
+	// Go back to the exchange about the cat, which has scrolled away.
+	for range 33 {
+		m = keyPress(t, m, typed("k")...)
+	}
+	attest.Equal(t, m.entries()[m.selected].text, "My cat is called Rex")
+	attest.Subsequence(t, visible(m), "Alice: My cat is called Rex")
+	m = keyPress(t, m, tea.KeyPressMsg{Code: tea.KeyDown})
+	attest.Equal(t, m.entries()[m.selected].text, "Tell me more about your CAT")
+
+	_, cmd := m.Update(tea.KeyPressMsg{Code: 'y', Text: "y"})
+	m, osc52 := runCopy(t, m, cmd)
+	attest.Equal(t, osc52, "Tell me more about your CAT")
+	attest.Equal(t, m.notice.text, "Copied the message to the clipboard.")
+	attest.Equal(t, local.copied, []string{"Tell me more about your CAT"})
+
+	_, cmd = m.Update(tea.KeyPressMsg{Code: 'y', Text: "Y"})
+	_, osc52 = runCopy(t, m, cmd)
+	attest.Equal(t, osc52, m.transcriptText())
+
+	m = keyPress(t, m, tea.KeyPressMsg{Code: tea.KeyEscape})
+	attest.False(t, m.selecting)
+	attest.True(t, m.textInput.Focused())
+}
+
+func TestCopyCommand(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel(startFakeServer(t))
+	m = runSlash(t, m, "/copy")
+	attest.True(t, m.notice.isErr)
+
+	m = longConversation(t)
+	m.textInput.SetValue("/copy")
+	next, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	m, osc52 := runCopy(t, next.(model), cmd)
+	attest.Equal(t, osc52, "Reply 19")
+	attest.Equal(t, m.notice.text, "Copied the last reply to the clipboard.")
+
+	m.textInput.SetValue("/copy all")
+	next, cmd = m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	_, osc52 = runCopy(t, next.(model), cmd)
+	attest.Subsequence(t, osc52, "Alice: My cat is called Rex\n")
+}
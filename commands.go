@@ -0,0 +1,346 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+)
+
+// The modes a session can talk to ELIZA in.
+const (
+	// bidiMode sends every sentence over one Converse stream, so ELIZA
+	// remembers the conversation.
+	bidiMode = "bidi"
+	// unaryMode sends each sentence in its own Say call.
+	unaryMode = "unary"
+)
+
+// slashCommands are the commands that can be typed into the TUI, in the order
+// /help lists them.
+var slashCommands = []struct {
+	name  string
+	usage string
+	help  string
+}{
+	{"save", "[path]", "save the conversation as text"},
+	{"export", "md|json [path]", "export the conversation as Markdown, or the whole log as JSON"},
+	{"clear", "", "clear the conversation history"},
+	{"rename", "<name>", "change your name, and introduce yourself again"},
+	{"reconnect", "", "start a new conversation stream"},
+	{"endpoint", "[url]", "show or change the ELIZA service"},
+	{"mode", "unary|bidi", "send sentences with Say or over a Converse stream"},
+	{"search", "[pattern]", "search the conversation"},
+	{"copy", "[all]", "copy the last reply, or the whole transcript"},
+	{"help", "", "list commands"},
+	{"quit", "", "quit"},
+}
+
+// A notice is a message for the user, shown below the input instead of being
+// sent to ELIZA.
+type notice struct {
+	text  string
+	isErr bool
+}
+
+// noticeMsg is the result of a command that finishes in the background.
+type noticeMsg notice
+
+var errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Red)
+
+func info(format string, args ...any) notice {
+	return notice{text: fmt.Sprintf(format, args...)}
+}
+
+func failure(format string, args ...any) notice {
+	return notice{text: fmt.Sprintf(format, args...), isErr: true}
+}
+
+func (n notice) view() string {
+	if n.text == "" {
+		return ""
+	}
+	if n.isErr {
+		return "\n\n" + errorStyle.Render(n.text)
+	}
+	return "\n\n" + faintStyle.Render(n.text)
+}
+
+// parseCommand splits text into a command name and its arguments. Text that
+// doesn't start with a slash, or starts with two, isn't a command.
+func parseCommand(text string) (string, []string, bool) {
+	if !strings.HasPrefix(text, "/") || strings.HasPrefix(text, "//") {
+		return "", nil, false
+	}
+	fields := strings.Fields(text[1:])
+	if len(fields) == 0 {
+		return "", nil, true
+	}
+	return fields[0], fields[1:], true
+}
+
+// waitsForReply reports whether the named command changes the conversation
+// ELIZA is replying to, so it can't run until she has.
+func waitsForReply(name string) bool {
+	switch name {
+	case "rename", "reconnect", "endpoint", "mode", "clear":
+		return true
+	}
+	return false
+}
+
+// runCommand runs the slash command in text.
+func (m model) runCommand(text string) (tea.Model, tea.Cmd) {
+	name, args, _ := parseCommand(text)
+	slog.Debug("running command", slog.String("command", name))
+	switch name {
+	case "save":
+		if len(args) > 1 {
+			return m.usage(name)
+		}
+		return m, m.forSession(writeTranscript(pathArg(args, "txt"), m.transcriptText()))
+	case "export":
+		if len(args) < 1 || len(args) > 2 || (args[0] != "md" && args[0] != "json") {
+			return m.usage(name)
+		}
+		if args[0] == "md" {
+			return m, m.forSession(writeTranscript(pathArg(args[1:], "md"), m.transcriptMarkdown()))
+		}
+		content, err := m.transcriptJSON()
+		if err != nil {
+			m.notice = failure("Couldn't export the conversation: %s.", err)
+			return m, nil
+		}
+		return m, m.forSession(writeTranscript(pathArg(args[1:], "json"), content))
+	case "clear":
+		// Keep the introductions, so it's still clear who's talking.
+		m.remarks = slices.DeleteFunc(slices.Clone(m.remarks), func(r remark) bool {
+			return r.kind == kindSentence || r.kind == kindReply
+		})
+		m.notice = info("Cleared the conversation history.")
+	case "rename":
+		if len(args) == 0 {
+			return m.usage(name)
+		}
+		if !m.hasIntroduced {
+			m.notice = failure("Introduce yourself first.")
+			return m, nil
+		}
+		return m.reintroduce(strings.Join(args, " "))
+	case "reconnect":
+		m.dropConversation()
+		m.notice = info("Reconnected on a new stream.")
+	case "endpoint":
+		return m.changeEndpoint(args)
+	case "mode":
+		if len(args) != 1 || (args[0] != unaryMode && args[0] != bidiMode) {
+			return m.usage(name)
+		}
+		if args[0] == unaryMode {
+			// Let the server handler return.
+			m.dropConversation()
+		}
+		m.mode = args[0]
+		m.notice = info("Now in %s mode.", m.mode)
+	case "search":
+		if m.accessible {
+			m.notice = failure("Search is off in accessible mode: use the terminal's scrollback.")
+			return m, nil
+		}
+		if !m.hasIntroduced {
+			m.notice = failure("There's nothing to search yet.")
+			return m, nil
+		}
+		return m.openSearch(strings.Join(args, " ")), nil
+	case "copy":
+		switch {
+		case len(args) == 1 && args[0] == "all":
+			return m, m.copyText(m.transcriptText(), "the transcript")
+		case len(args) > 0:
+			return m.usage(name)
+		}
+		reply, ok := m.lastReply()
+		if !ok {
+			m.notice = failure("ELIZA hasn't said anything yet.")
+			return m, nil
+		}
+		return m, m.copyText(reply, "the last reply")
+	case "help":
+		var b strings.Builder
+		for _, c := range slashCommands {
+			fmt.Fprintf(&b, "%-24s %s\n", strings.TrimSpace("/"+c.name+" "+c.usage), c.help)
+		}
+		b.WriteString("Start a sentence with // to send it with a leading slash.")
+		m.notice = info("%s", b.String())
+	case "quit":
+		m.closeConversations()
+		return m, tea.Quit
+	default:
+		m.notice = failure("Unknown command %q: try /help.", "/"+name)
+	}
+	return m, nil
+}
+
+func (m model) usage(name string) (tea.Model, tea.Cmd) {
+	for _, c := range slashCommands {
+		if c.name == name {
+			m.notice = failure("Usage: /%s %s", c.name, c.usage)
+		}
+	}
+	return m, nil
+}
+
+// changeEndpoint switches the session to the ELIZA service at the URL in
+// args, or shows the current one if there's no URL.
+func (m model) changeEndpoint(args []string) (tea.Model, tea.Cmd) {
+	if len(args) == 0 {
+		m.notice = info("Talking to %s.", m.url)
+		return m, nil
+	}
+	if len(args) > 1 {
+		return m.usage("endpoint")
+	}
+	e, err := m.findEndpoint(args[0])
+	if err != nil {
+		m.notice = failure("Can't use %s: %s.", args[0], err)
+		return m, nil
+	}
+	m.dropConversation()
+	m.client, m.url = e.client, e.url
+	m.textInput.SetSuggestions(m.completions())
+	slog.Info("endpoint changed", slog.String("url", e.url))
+	m.notice = info("Now talking to %s.", e.url)
+	return m, nil
+}
+
+// findEndpoint returns the endpoint for rawURL, dialing it if it's new.
+func (m *model) findEndpoint(rawURL string) (endpoint, error) {
+	for _, e := range m.endpoints {
+		if e.url == rawURL {
+			return e, nil
+		}
+	}
+	if m.dial == nil {
+		return endpoint{}, errors.New("only the endpoints given on the command line are available")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return endpoint{}, err
+	}
+	if u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "h2c") {
+		return endpoint{}, errors.New("not an http, https or h2c URL")
+	}
+	e, err := m.dial(rawURL)
+	if err != nil {
+		return endpoint{}, err
+	}
+	m.endpoints = append(m.endpoints, e)
+	return e, nil
+}
+
+// completions returns every command line that tab can complete to.
+func (m model) completions() []string {
+	var completions []string
+	for _, c := range slashCommands {
+		switch c.name {
+		case "export":
+			completions = append(completions, "/export ", "/export md", "/export json")
+		case "copy":
+			completions = append(completions, "/copy", "/copy all")
+		case "mode":
+			completions = append(completions, "/mode ", "/mode "+unaryMode, "/mode "+bidiMode)
+		case "endpoint":
+			completions = append(completions, "/endpoint ")
+			for _, e := range m.endpoints {
+				completions = append(completions, "/endpoint "+e.url)
+			}
+		case "rename", "search":
+			completions = append(completions, "/"+c.name+" ")
+		default:
+			completions = append(completions, "/"+c.name)
+		}
+	}
+	return completions
+}
+
+// pathArg returns the path in args, or a new file name with the extension.
+func pathArg(args []string, ext string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return fmt.Sprintf("eliza-%s.%s", time.Now().Format("20060102-150405"), ext)
+}
+
+// writeTranscript writes content to path.
+func writeTranscript(path, content string) tea.Cmd {
+	return func() tea.Msg {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return noticeMsg(failure("Couldn't save the conversation: %s.", err))
+		}
+		return noticeMsg(info("Saved the conversation to %s.", path))
+	}
+}
+
+// reintroduce starts the conversation afresh under a new name. What was said
+// before stays in the history, above a separator.
+func (m model) reintroduce(name string) (tea.Model, tea.Cmd) {
+	m.dropConversation()
+	m.remarks = append(m.remarks, remark{
+		kind:    kindRename,
+		at:      time.Now(),
+		text:    fmt.Sprintf("%s is now %s", m.name, name),
+		status:  statusSent,
+		backend: m.url,
+	})
+	m.name = name
+	m.waitingForResponse = true
+	slog.Debug("reintroducing")
+	return m.sendIntroduction(name)
+}
+
+// exchanges returns the lines of the conversation that were said, with who
+// said each of them. Separators have no speaker.
+func (s session) exchanges() [][2]string {
+	var lines [][2]string
+	for _, r := range s.remarks {
+		if r.status == statusSent {
+			lines = append(lines, [2]string{r.speaker, r.text})
+		}
+	}
+	return lines
+}
+
+func (s session) transcriptText() string {
+	var b strings.Builder
+	for _, line := range s.exchanges() {
+		if line[0] == "" {
+			fmt.Fprintf(&b, "-- %s --\n", line[1])
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s\n", line[0], line[1])
+	}
+	return b.String()
+}
+
+func (s session) transcriptMarkdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Conversation with ELIZA\n\n")
+	if s.url != "" {
+		fmt.Fprintf(&b, "With %s, on %s.\n\n", s.url, time.Now().Format(time.DateOnly))
+	}
+	for _, line := range s.exchanges() {
+		if line[0] == "" {
+			fmt.Fprintf(&b, "---\n\n*%s*\n\n", line[1])
+			continue
+		}
+		fmt.Fprintf(&b, "**%s:** %s\n\n", line[0], line[1])
+	}
+	return b.String()
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"go.akshayshah.org/attest"
+)
+
+// runSlash types text into the active tab and presses enter, running any
+// command it returns.
+func runSlash(t *testing.T, m model, text string) model {
+	t.Helper()
+
+	m.textInput.SetValue(text)
+	next, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	m = next.(model)
+	if cmd != nil {
+		next, _ = m.Update(cmd())
+		m = next.(model)
+	}
+	return m
+}
+
+func TestCommandsAreNotSent(t *testing.T) {
+	t.Parallel()
+
+	client, handler := startFakeServerWithHandler(t)
+	m := introduceTab(t, initialModel(client), "Alice")
+
+	m = runSlash(t, m, "/nonsense")
+	attest.True(t, m.notice.isErr)
+	attest.Subsequence(t, m.View().Content, `Unknown command "/nonsense": try /help.`)
+	m = runSlash(t, m, "/mode sideways")
+	attest.Equal(t, m.notice, failure("Usage: /mode unary|bidi"), attest.Allow(notice{}))
+	m = runSlash(t, m, "/help")
+	attest.Subsequence(t, m.notice.text, "/endpoint [url]")
+	attest.Equal(t, len(said(m)), 0)
+	attest.Equal(t, handler.converseCalls.Load(), int32(0))
+
+	// A double slash escapes a sentence that starts with one.
+	m = sendMessage(t, m, "//etc/passwd is a file")
+	attest.Equal(t, said(m), []string{"/etc/passwd is a file"})
+	attest.Equal(t, m.notice, notice{}, attest.Allow(notice{}))
+	m.closeConversations()
+}
+
+func TestCommandsWhileWaitingForReply(t *testing.T) {
+	t.Parallel()
+
+	m := introduceTab(t, initialModel(startFakeServer(t)), "Alice")
+	m.textInput.SetValue("hello")
+	next, _ := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	m = next.(model)
+	attest.True(t, m.waitingForResponse)
+
+	// Commands that change the conversation wait for the reply...
+	m = runSlash(t, m, "/rename Bob")
+	attest.Equal(t, m.notice, failure("Wait for ELIZA to reply before running /rename."), attest.Allow(notice{}))
+	attest.Equal(t, m.name, "Alice")
+	attest.Equal(t, m.textInput.Value(), "/rename Bob")
+
+	// ...but the rest don't.
+	m = runSlash(t, m, "/help")
+	attest.Subsequence(t, m.notice.text, "/endpoint [url]")
+	m.textInput.SetValue("/quit")
+	_, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	attest.True(t, cmd != nil, attest.Fatal())
+	_, quit := cmd().(tea.QuitMsg)
+	attest.True(t, quit)
+}
+
+func TestModeCommand(t *testing.T) {
+	t.Parallel()
+
+	client, handler := startFakeServerWithHandler(t)
+	m := introduceTab(t, initialModel(client), "Alice")
+	m = sendMessage(t, m, "over the stream")
+
+	m = runSlash(t, m, "/mode unary")
+	attest.Equal(t, m.mode, unaryMode)
+	attest.Equal(t, m.conversation, nil)
+	<-handler.converseDone
+	m = sendMessage(t, m, "in a Say call")
+	attest.Equal(t, replies(m)[1], `I see. You said: "in a Say call". Tell me more.`)
+	attest.Equal(t, handler.converseCalls.Load(), int32(1))
+
+	m = runSlash(t, m, "/mode bidi")
+	m = sendMessage(t, m, "over a new stream")
+	attest.Equal(t, handler.converseCalls.Load(), int32(2))
+	m.closeConversations()
+}
+
+func TestHistoryCommands(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	m := introduceTab(t, initialModel(startFakeServer(t)), "Alice")
+	m = sendMessage(t, m, "hello")
+
+	text := filepath.Join(dir, "conversation.txt")
+	m = runSlash(t, m, "/save "+text)
+	attest.Equal(t, m.notice, info("Saved the conversation to %s.", text), attest.Allow(notice{}))
+	got, err := os.ReadFile(text)
+	attest.Ok(t, err)
+	attest.Equal(t, string(got), "Eliza: Hello Alice, I'm ELIZA.\n"+
+		"Eliza: How are you feeling today?\n"+
+		"Eliza: I'm here to help you.\n"+
+		"Alice: hello\n"+
+		"Eliza: I see. You said: \"hello\". Tell me more.\n")
+
+	md := filepath.Join(dir, "conversation.md")
+	m = runSlash(t, m, "/export md "+md)
+	got, err = os.ReadFile(md)
+	attest.Ok(t, err)
+	attest.Subsequence(t, string(got), "**Alice:** hello\n\n")
+
+	m = runSlash(t, m, "/export pdf")
+	attest.True(t, m.notice.isErr)
+	m = runSlash(t, m, "/save "+filepath.Join(dir, "missing", "conversation.txt"))
+	attest.True(t, m.notice.isErr)
+
+	m = runSlash(t, m, "/clear")
+	attest.Equal(t, len(said(m)), 0)
+	attest.Equal(t, len(replies(m)), 0)
+	m.closeConversations()
+}
+
+func TestExportJSON(t *testing.T) {
+	t.Parallel()
+
+	m := initialModelWithEndpoints([]endpoint{{url: "https://eliza.example.com", client: startFakeServer(t)}})
+	m = introduceTab(t, m, "Alice")
+	m = sendMessage(t, m, "hello")
+	m.addSentence("never sent", statusCancelled)
+
+	path := filepath.Join(t.TempDir(), "conversation.json")
+	m = runSlash(t, m, "/export json "+path)
+	attest.False(t, m.notice.isErr, attest.Sprintf("%s", m.notice.text))
+	data, err := os.ReadFile(path)
+	attest.Ok(t, err, attest.Fatal())
+	var remarks []jsonRemark
+	attest.Ok(t, json.Unmarshal(data, &remarks), attest.Fatal())
+
+	// The whole log is exported, including what was never sent.
+	attest.Equal(t, len(remarks), 6, attest.Fatal())
+	attest.Equal(t, remarks[0].Kind, "introduction")
+	sentence, reply, cancelled := remarks[3], remarks[4], remarks[5]
+	attest.Equal(t, sentence.Kind, "sentence")
+	attest.Equal(t, sentence.Speaker, "Alice")
+	attest.Equal(t, sentence.Status, "sent")
+	attest.Equal(t, sentence.Backend, "https://eliza.example.com")
+	attest.Equal(t, reply.Kind, "reply")
+	attest.Equal(t, reply.Speaker, "Eliza")
+	attest.True(t, reply.LatencyMS > 0)
+	attest.Equal(t, cancelled.Status, "cancelled")
+	_, err = time.Parse(time.RFC3339Nano, cancelled.Time)
+	attest.Ok(t, err)
+	m.closeConversations()
+}
+
+func TestEndpointCommand(t *testing.T) {
+	t.Parallel()
+
+	first, firstHandler := startFakeServerWithHandler(t)
+	second, secondHandler := startFakeServerWithHandler(t)
+	m := initialModelWithEndpoints([]endpoint{{url: "https://first.example.com", client: first}})
+	m = introduceTab(t, m, "Alice")
+
+	m = runSlash(t, m, "/endpoint https://second.example.com")
+	attest.True(t, m.notice.isErr)
+
+	m.dial = func(u string) (endpoint, error) {
+		return endpoint{url: u, client: second}, nil
+	}
+	m = runSlash(t, m, "/endpoint ftp://second.example.com")
+	attest.True(t, m.notice.isErr)
+	m = sendMessage(t, m, "hello first")
+	m = runSlash(t, m, "/endpoint https://second.example.com")
+	attest.Equal(t, m.notice, info("Now talking to https://second.example.com."), attest.Allow(notice{}))
+	attest.Equal(t, m.url, "https://second.example.com")
+	<-firstHandler.converseDone
+	m = sendMessage(t, m, "hello second")
+	attest.Equal(t, secondHandler.converseCalls.Load(), int32(1))
+	attest.Equal(t, len(m.endpoints), 2)
+	m.closeConversations()
+}
+
+func TestTabCompletesCommands(t *testing.T) {
+	t.Parallel()
+
+	m := initialModelWithEndpoints([]endpoint{{url: "h2c://localhost:8080", client: startFakeServer(t)}})
+	press := func(m model, text string) model {
+		for _, r := range text {
+			next, _ := m.Update(tea.KeyPressMsg{Code: r, Text: string(r)})
+			m = next.(model)
+		}
+		return m
+	}
+	tab := func(m model) model {
+		next, _ := m.Update(tea.KeyPressMsg{Code: tea.KeyTab})
+		return next.(model)
+	}
+
+	m = tab(press(m, "/he"))
+	attest.Equal(t, m.textInput.Value(), "/help")
+	m.textInput.SetValue("")
+
+	m = tab(press(m, "/mo"))
+	attest.Equal(t, m.textInput.Value(), "/mode ")
+	m = tab(press(m, "u"))
+	attest.Equal(t, m.textInput.Value(), "/mode unary")
+	m.textInput.SetValue("")
+
+	m = tab(press(m, "/export j"))
+	attest.Equal(t, m.textInput.Value(), "/export json")
+	m.textInput.SetValue("")
+
+	m = tab(press(m, "/endpoint h"))
+	attest.Equal(t, m.textInput.Value(), "/endpoint h2c://localhost:8080")
+}
+
+func TestRenameReintroduces(t *testing.T) {
+	t.Parallel()
+
+	client, handler := startFakeServerWithHandler(t)
+	m := initialModel(client)
+	m = runSlash(t, m, "/rename Bob")
+	attest.True(t, m.notice.isErr)
+
+	m = introduceTab(t, m, "Alice")
+	m = sendMessage(t, m, "hello")
+
+	m.textInput.SetValue("/rename Bob")
+	next, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	m = next.(model)
+	attest.True(t, m.waitingForResponse)
+	attest.Equal(t, m.conversation, nil)
+	<-handler.converseDone
+	attest.Subsequence(t, m.View().Content, "-- Alice is now Bob --")
+
+	next, _ = m.Update(cmd())
+	m = next.(model)
+	m = sendMessage(t, m, "hello again")
+	attest.Equal(t, m.name, "Bob")
+	attest.Equal(t, handler.converseCalls.Load(), int32(2))
+	attest.Equal(t, m.transcriptText(), "Eliza: Hello Alice, I'm ELIZA.\n"+
+		"Eliza: How are you feeling today?\n"+
+		"Eliza: I'm here to help you.\n"+
+		"Alice: hello\n"+
+		"Eliza: I see. You said: \"hello\". Tell me more.\n"+
+		"-- Alice is now Bob --\n"+
+		"Eliza: Hello Bob, I'm ELIZA.\n"+
+		"Eliza: How are you feeling today?\n"+
+		"Eliza: I'm here to help you.\n"+
+		"Bob: hello again\n"+
+		"Eliza: I see. You said: \"hello again\". Tell me more.\n")
+	attest.Subsequence(t, m.View().Content, "Alice: hello\n")
+	m.closeConversations()
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
+	"charm.land/bubbles/v2/key"
+	"charm.land/bubbles/v2/spinner"
+	"charm.land/bubbles/v2/textinput"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"connectrpc.com/connect"
+)
+
+// compareModel sends each sentence to two ELIZA services over their own
+// Converse streams, and shows the replies side by side.
+type compareModel struct {
+	sides [2]*compareSide
+	rows  []compareRow
+	width int
+
+	keys      keyMap
+	showHelp  bool
+	textInput textinput.Model
+	spinner   spinner.Model
+}
+
+type compareSide struct {
+	url          string
+	client       elizav1connect.ElizaServiceClient
+	conversation *connect.BidiStreamForClient[elizav1.ConverseRequest, elizav1.ConverseResponse]
+	total        time.Duration
+	replies      int
+}
+
+// compareRow is one sentence and each side's reply to it.
+type compareRow struct {
+	said    string
+	replies [2]compareReply
+}
+
+type compareReply struct {
+	done     bool
+	sentence string
+	latency  time.Duration
+	err      error
+}
+
+// compareReplyMsg is one side's reply to the sentence in a row.
+type compareReplyMsg struct {
+	side  int
+	row   int
+	reply compareReply
+}
+
+var (
+	diffStyle   = lipgloss.NewStyle().Foreground(lipgloss.Yellow).Bold(true)
+	headerStyle = lipgloss.NewStyle().Bold(true)
+	faintStyle  = lipgloss.NewStyle().Faint(true)
+)
+
+func newCompareModel(a, b endpoint) compareModel {
+	textInput := textinput.New()
+	textInput.Placeholder = "Say something to both"
+	textInput.CharLimit = 156
+	textInput.SetWidth(50)
+	textInput.Focus()
+
+	return compareModel{
+		sides: [2]*compareSide{
+			{url: a.url, client: a.client},
+			{url: b.url, client: b.client},
+		},
+		width:     80,
+		keys:      defaultKeyMap(),
+		textInput: textInput,
+		spinner:   spinner.New(),
+	}
+}
+
+func (m compareModel) Init() tea.Cmd {
+	return tea.Batch(textinput.Blink, m.spinner.Tick)
+}
+
+func (m compareModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.KeyPressMsg:
+		if m.showHelp {
+			if key.Matches(msg, m.keys.help) || msg.String() == "esc" {
+				m.showHelp = false
+			} else if key.Matches(msg, m.keys.quit) {
+				m.closeConversations()
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		switch {
+		case key.Matches(msg, m.keys.send):
+			if m.waiting() {
+				return m, nil
+			}
+			text := m.textInput.Value()
+			if text == "" {
+				return m, nil
+			}
+			m.textInput.Reset()
+			m.rows = append(m.rows, compareRow{said: text})
+			row := len(m.rows) - 1
+			return m, tea.Batch(m.say(0, row, text), m.say(1, row, text))
+		case key.Matches(msg, m.keys.help):
+			m.showHelp = true
+			return m, nil
+		case key.Matches(msg, m.keys.quit):
+			m.closeConversations()
+			return m, tea.Quit
+		default:
+			m.textInput, cmd = m.textInput.Update(msg)
+			return m, cmd
+		}
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		return m, nil
+	case spinner.TickMsg:
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	case compareReplyMsg:
+		m.rows[msg.row].replies[msg.side] = msg.reply
+		side := m.sides[msg.side]
+		if msg.reply.err != nil {
+			slog.Error("compare request failed", append(errorAttrs(msg.reply.err), slog.String("url", side.url))...)
+			// Start a fresh stream for the next sentence.
+			side.close()
+		} else {
+			side.total += msg.reply.latency
+			side.replies++
+		}
+		return m, nil
+	default:
+		m.textInput, cmd = m.textInput.Update(msg)
+		return m, cmd
+	}
+}
+
+// waiting reports whether either side has yet to reply to the last sentence.
+func (m compareModel) waiting() bool {
+	if len(m.rows) == 0 {
+		return false
+	}
+	last := m.rows[len(m.rows)-1]
+	return !last.replies[0].done || !last.replies[1].done
+}
+
+// say sends text to one side, and measures how long it takes to reply.
+func (m compareModel) say(i, row int, text string) tea.Cmd {
+	side := m.sides[i]
+	if side.conversation == nil {
+		side.conversation = side.client.Converse(context.Background())
+	}
+	conversation := side.conversation
+	return func() tea.Msg {
+		start := time.Now()
+		reply := compareReply{done: true}
+		if err := conversation.Send(&elizav1.ConverseRequest{Sentence: text}); err != nil {
+			reply.err = err
+		} else if res, err := conversation.Receive(); err != nil {
+			reply.err = err
+		} else {
+			reply.sentence = res.Sentence
+		}
+		reply.latency = time.Since(start)
+		return compareReplyMsg{side: i, row: row, reply: reply}
+	}
+}
+
+func (s *compareSide) close() {
+	if s.conversation != nil {
+		_ = s.conversation.CloseRequest()
+		_ = s.conversation.CloseResponse()
+		s.conversation = nil
+	}
+}
+
+func (m compareModel) closeConversations() {
+	for _, side := range m.sides {
+		side.close()
+	}
+}
+
+func (m compareModel) View() tea.View {
+	if m.showHelp {
+		send := m.keys.send
+		send.SetEnabled(!m.waiting())
+		return tea.NewView(helpView(m.keys.help, [][]key.Binding{
+			{send},
+			{m.keys.help, m.keys.quit},
+		}))
+	}
+	var b strings.Builder
+	b.WriteString(m.columns(
+		headerStyle.Render(m.sides[0].url)+"\n"+faintStyle.Render(m.sides[0].summary()),
+		headerStyle.Render(m.sides[1].url)+"\n"+faintStyle.Render(m.sides[1].summary()),
+	))
+	b.WriteString("\n\n")
+
+	for _, row := range m.rows {
+		b.WriteString("You: ")
+		b.WriteString(row.said)
+		b.WriteString("\n")
+		b.WriteString(m.columns(m.renderReplies(row)))
+		b.WriteString("\n")
+	}
+	if !m.waiting() {
+		b.WriteString(m.textInput.View())
+	}
+	return tea.NewView(b.String())
+}
+
+// columns lays out left and right side by side, each taking half the width
+// of the terminal.
+func (m compareModel) columns(left, right string) string {
+	column := lipgloss.NewStyle().Width(max((m.width-3)/2, 20))
+	left, right = column.Render(left), column.Render(right)
+	height := max(lipgloss.Height(left), lipgloss.Height(right))
+	gutter := strings.TrimSuffix(strings.Repeat(" │ \n", height), "\n")
+	return lipgloss.JoinHorizontal(lipgloss.Top, left, gutter, right)
+}
+
+// renderReplies renders both replies in a row, highlighting the words that
+// differ between them.
+func (m compareModel) renderReplies(row compareRow) (string, string) {
+	var rendered [2]string
+	a, b := row.replies[0], row.replies[1]
+	same := a.done && b.done && a.err == nil && b.err == nil && a.sentence == b.sentence
+	var highlights [2][]bool
+	if a.done && b.done && a.err == nil && b.err == nil && !same {
+		highlights[0], highlights[1] = diffWords(strings.Fields(a.sentence), strings.Fields(b.sentence))
+	}
+	for i, reply := range row.replies {
+		switch {
+		case !reply.done:
+			rendered[i] = "Eliza: " + m.spinner.View()
+		case reply.err != nil:
+			rendered[i] = diffStyle.Render(fmt.Sprintf("error: %s", reply.err))
+		default:
+			words := strings.Fields(reply.sentence)
+			for j, word := range words {
+				if highlights[i] != nil && highlights[i][j] {
+					words[j] = diffStyle.Render(word)
+				}
+			}
+			marker := "≠"
+			if same {
+				marker = "="
+			}
+			rendered[i] = fmt.Sprintf("Eliza: %s\n%s", strings.Join(words, " "),
+				faintStyle.Render(fmt.Sprintf("%s %s", marker, reply.latency.Round(time.Millisecond))))
+		}
+	}
+	return rendered[0], rendered[1]
+}
+
+func (s *compareSide) summary() string {
+	switch s.replies {
+	case 0:
+		return "no replies yet"
+	case 1:
+		return fmt.Sprintf("1 reply, latency %s", s.total.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("%d replies, mean latency %s", s.replies, (s.total / time.Duration(s.replies)).Round(time.Millisecond))
+}
+
+// diffWords reports, for each word in a and b, whether it's missing from
+// their longest common subsequence: that is, whether it differs.
+func diffWords(a, b []string) ([]bool, []bool) {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	diffA, diffB := make([]bool, len(a)), make([]bool, len(b))
+	for i := range diffA {
+		diffA[i] = true
+	}
+	for j := range diffB {
+		diffB[j] = true
+	}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			diffA[i], diffB[j] = false, false
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return diffA, diffB
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/x/ansi"
+	"go.akshayshah.org/attest"
+)
+
+func TestCompareFansOutToBothSides(t *testing.T) {
+	t.Parallel()
+
+	fake, handler := startFakeServerWithHandler(t)
+	m := newCompareModel(
+		endpoint{url: "https://fake.example.com", client: fake},
+		endpoint{url: "h2c://local", client: startLocalServer(t)},
+	)
+
+	for _, sentence := range []string{"I am sad", "My mother hates me"} {
+		m.textInput.SetValue(sentence)
+		next, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+		m = next.(compareModel)
+		attest.True(t, m.waiting())
+		batch, ok := cmd().(tea.BatchMsg)
+		attest.True(t, ok, attest.Fatal())
+		for _, cmd := range batch {
+			next, _ = m.Update(cmd())
+			m = next.(compareModel)
+		}
+		attest.False(t, m.waiting())
+	}
+
+	attest.Equal(t, len(m.rows), 2)
+	attest.Equal(t, m.rows[0].replies[0].sentence, `I see. You said: "I am sad". Tell me more.`)
+	attest.Equal(t, m.rows[0].replies[1].sentence, "Is it because you are sad that you came to me?")
+	attest.Equal(t, m.sides[0].replies, 2)
+	attest.Equal(t, m.sides[1].replies, 2)
+	// Each side keeps a single stream.
+	attest.Equal(t, handler.converseCalls.Load(), int32(1))
+
+	view := ansi.Strip(m.View().Content)
+	attest.Subsequence(t, view, "https://fake.example.com")
+	attest.Subsequence(t, view, "2 replies, mean latency")
+	attest.Subsequence(t, view, "You: I am sad")
+	attest.Subsequence(t, view, "≠")
+
+	_, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	attest.True(t, cmd != nil)
+	<-handler.converseDone
+}
+
+func TestCompareShowsErrorsInline(t *testing.T) {
+	t.Parallel()
+
+	m := newCompareModel(
+		endpoint{url: "a", client: startFakeServerWithErrors(t)},
+		endpoint{url: "b", client: startLocalServer(t)},
+	)
+	m.textInput.SetValue("hello")
+	next, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	m = next.(compareModel)
+	for _, cmd := range cmd().(tea.BatchMsg) {
+		next, _ = m.Update(cmd())
+		m = next.(compareModel)
+	}
+	attest.Error(t, m.rows[0].replies[0].err)
+	attest.Equal(t, m.sides[0].conversation, nil)
+	attest.Subsequence(t, ansi.Strip(m.View().Content), "error: unknown: converse error")
+	m.closeConversations()
+}
+
+func TestDiffWords(t *testing.T) {
+	t.Parallel()
+
+	a := strings.Fields("How long have you been sad?")
+	b := strings.Fields("Have you been sad for long?")
+	diffA, diffB := diffWords(a, b)
+	attest.Equal(t, diffA, []bool{true, true, true, false, false, true})
+	attest.Equal(t, diffB, []bool{true, false, false, true, true, true})
+}
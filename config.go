@@ -0,0 +1,82 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// config is the TUI's configuration file, in JSON. For example:
+//
+//	{
+//	  "keys": {
+//	    "preset": "vim",
+//	    "bindings": {"new-tab": ["alt+t"]}
+//	  },
+//	  "notify": {"bell": false, "command": "notify-send ELIZA \"$(cat)\""}
+//	}
+type config struct {
+	Keys struct {
+		// Preset is the key map to start from: default, vim or emacs.
+		Preset string `json:"preset"`
+		// Bindings replaces the keys of actions, by action name.
+		Bindings map[string][]string `json:"bindings"`
+	} `json:"keys"`
+	Notify struct {
+		// Bell and Desktop turn the terminal bell and desktop
+		// notifications for replies off, when false.
+		Bell    *bool `json:"bell"`
+		Desktop *bool `json:"desktop"`
+		// Command is run with each reply on stdin.
+		Command string `json:"command"`
+	} `json:"notify"`
+}
+
+// defaultConfigPath returns where the configuration file is read from when
+// -config isn't set.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "eliza", "config.json")
+}
+
+// loadConfig reads the configuration file at path. If optional is set, a
+// missing file is the same as an empty one.
+func loadConfig(path string, optional bool) (*config, error) {
+	c := &config{}
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// keyMap returns the key bindings c configures.
+func (c *config) keyMap() (keyMap, error) {
+	return newKeyMap(c.Keys.Preset, c.Keys.Bindings)
+}
+
+// notifier returns the notifications c configures. A command given with
+// -notify replaces c's.
+func (c *config) notifier(command string) notifier {
+	return notifier{
+		bell:    c.Notify.Bell == nil || *c.Notify.Bell,
+		desktop: c.Notify.Desktop == nil || *c.Notify.Desktop,
+		command: cmp.Or(command, c.Notify.Command),
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.json")
+	c, err := loadConfig(missing, true)
+	attest.Ok(t, err)
+	attest.Equal(t, c.Keys.Preset, "")
+	_, err = loadConfig(missing, false)
+	attest.Error(t, err)
+
+	path := filepath.Join(dir, "config.json")
+	attest.Ok(t, os.WriteFile(path, []byte(`{"keys": {"preset": "emacs", "bindings": {"quit": ["ctrl+q"]}}}`), 0o644))
+	c, err = loadConfig(path, false)
+	attest.Ok(t, err)
+	keys, err := c.keyMap()
+	attest.Ok(t, err)
+	attest.Equal(t, keys.quit.Keys(), []string{"ctrl+q"})
+	attest.Equal(t, keys.nextTab.Keys(), []string{"alt+n", "ctrl+tab"})
+
+	n := c.notifier("")
+	attest.True(t, n.bell)
+	attest.True(t, n.desktop)
+	attest.Equal(t, n.command, "")
+
+	attest.Ok(t, os.WriteFile(path, []byte(`{"notify": {"bell": false, "command": "say"}}`), 0o644))
+	c, err = loadConfig(path, false)
+	attest.Ok(t, err)
+	n = c.notifier("")
+	attest.False(t, n.bell)
+	attest.True(t, n.desktop)
+	attest.Equal(t, n.command, "say")
+	attest.Equal(t, c.notifier("notify-send").command, "notify-send")
+
+	attest.Ok(t, os.WriteFile(path, []byte(`{"keys": `), 0o644))
+	_, err = loadConfig(path, false)
+	attest.Error(t, err)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
+	tea "charm.land/bubbletea/v2"
+	"connectrpc.com/connect"
+)
+
+// A poster delivers messages to a running program from outside its event
+// loop. A [*tea.Program] is one.
+type poster interface {
+	Send(msg tea.Msg)
+}
+
+// postFunc is a poster that calls itself, for wiring up a program that
+// doesn't exist yet.
+type postFunc func(msg tea.Msg)
+
+func (f postFunc) Send(msg tea.Msg) {
+	f(msg)
+}
+
+// A conversation is an open Converse stream. Its replies are read by a
+// goroutine of their own and posted to the program as they arrive, so it
+// doesn't matter how many ELIZA sends for each sentence, or when.
+type conversation struct {
+	id     int // tells the stream's messages from an earlier stream's
+	stream *connect.BidiStreamForClient[elizav1.ConverseRequest, elizav1.ConverseResponse]
+	cancel context.CancelFunc
+	// receiving starts the receive loop on the first send: until then,
+	// there's no request for the server to respond to.
+	receiving sync.Once
+	done      chan struct{} // closed once the response is closed
+}
+
+// closeWait is how long quitting waits for servers to end their streams.
+const closeWait = time.Second
+
+// A streamMsg is a message about the Converse stream with the given ID. It's
+// ignored if that stream has since been closed.
+type streamMsg struct {
+	stream int
+	msg    tea.Msg
+}
+
+// conversationEndedMsg reports that the server ended the Converse stream.
+type conversationEndedMsg struct{}
+
+// streamFailedMsg reports that receiving from the Converse stream failed.
+type streamFailedMsg struct {
+	err error
+}
+
+// openConversation opens a new Converse stream for the session.
+func (s *session) openConversation() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.streams++
+	stream := s.client.Converse(ctx)
+	stream.RequestHeader().Set(sessionHeader, s.sessionID)
+	s.conversation = &conversation{
+		id:     s.streams,
+		stream: stream,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	slog.Debug("conversation opened")
+}
+
+// closeConversation closes the Converse stream, if one was opened, so the
+// server handler can return.
+func (s session) closeConversation() {
+	if s.conversation == nil {
+		return
+	}
+	c := s.conversation
+	if err := c.stream.CloseRequest(); err != nil {
+		slog.Warn("closing conversation request", errorAttrs(err)...)
+	}
+	// The receive loop closes the response when the server ends the
+	// stream, or here if there isn't one.
+	c.receiving.Do(c.closeResponse)
+	slog.Debug("conversation closed")
+}
+
+// wait waits for the server to end the stream, cancelling it if that takes
+// longer than closeWait.
+func (c *conversation) wait() {
+	select {
+	case <-c.done:
+	case <-time.After(closeWait):
+		c.cancel()
+		c.receiving.Do(c.closeResponse)
+		<-c.done
+	}
+}
+
+// dropConversation closes the Converse stream, so that the next sentence
+// opens another.
+func (s *session) dropConversation() {
+	s.closeConversation()
+	s.conversation = nil
+}
+
+// sayOnStream sends text over the Converse stream. ELIZA's replies come from
+// the receive loop, not the returned command.
+func (m model) sayOnStream(text string) tea.Cmd {
+	c, program, id := m.conversation, m.program, m.id
+	post := func(msg tea.Msg) {
+		program.Send(sessionMsg{id: id, msg: streamMsg{stream: c.id, msg: msg}})
+	}
+	return func() tea.Msg {
+		err := c.stream.Send(&elizav1.ConverseRequest{Sentence: text})
+		// Receive even if sending failed: when the stream has failed,
+		// the reason comes from receiving.
+		c.receiving.Do(func() {
+			go c.receive(post)
+		})
+		if err != nil && !errors.Is(err, io.EOF) {
+			return streamMsg{stream: c.id, msg: sayFailed(text, err)}
+		}
+		return nil
+	}
+}
+
+// receive posts each of the stream's replies until it ends.
+func (c *conversation) receive(post func(tea.Msg)) {
+	defer c.closeResponse()
+	for {
+		res, err := c.stream.Receive()
+		if errors.Is(err, io.EOF) {
+			post(conversationEndedMsg{})
+			return
+		}
+		if err != nil {
+			post(streamFailedMsg{err: err})
+			return
+		}
+		received := time.Now()
+		// Eliza is too fast to respond, generally.
+		// Wait a second to make things appear slow.
+		time.Sleep(time.Second)
+		post(sayMsg{sentence: res.Sentence, received: received})
+	}
+}
+
+func (c *conversation) closeResponse() {
+	if err := c.stream.CloseResponse(); err != nil {
+		slog.Warn("closing conversation response", errorAttrs(err)...)
+	}
+	c.cancel()
+	close(c.done)
+}
+
+// updateStream applies a message about the Converse stream, unless the
+// stream has since been closed.
+func (m model) updateStream(msg streamMsg) (tea.Model, tea.Cmd) {
+	if m.conversation == nil || m.conversation.id != msg.stream {
+		return m, nil
+	}
+	switch inner := msg.msg.(type) {
+	case conversationEndedMsg:
+		m.dropConversation()
+		slog.Info("conversation ended by the server")
+		if !m.waitingForResponse {
+			return m, nil
+		}
+		return m.sayFailed(errors.New("ELIZA ended the conversation without replying"))
+	case streamFailedMsg:
+		if !m.waitingForResponse {
+			// Nothing's lost: the next sentence opens a new stream.
+			slog.Warn("conversation failed", errorAttrs(inner.err)...)
+			m.dropConversation()
+			m.notice = failure("The conversation failed: the next sentence opens a new stream.")
+			return m, nil
+		}
+		text := ""
+		if i := m.lastSent(); i >= 0 {
+			text = m.remarks[i].text
+		}
+		return m.Update(sayFailed(text, inner.err))
+	default:
+		return m.Update(msg.msg)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A doctor holds the state of one conversation with a local ELIZA, following
+// Weizenbaum's DOCTOR script: keywords are ranked, each keyword's
+// decomposition patterns are tried in order, and the reassembly templates of
+// the first pattern that matches are used in rotation.
+type doctor struct {
+	// memory holds responses saved by "my" sentences, to be brought up
+	// when nothing else matches.
+	memory []string
+	// turns counts how often each decomposition has been used, so
+	// successive replies cycle through its reassemblies.
+	turns map[string]int
+}
+
+func newDoctor() *doctor {
+	return &doctor{turns: make(map[string]int)}
+}
+
+// introduction returns ELIZA's greeting for name.
+func introduction(name string) []string {
+	return []string{
+		fmt.Sprintf("Hi %s. I'm Eliza.", name),
+		"Before we begin, I should tell you that I'm a local imitation of the ELIZA demo service.",
+		"How are you feeling today?",
+	}
+}
+
+// welcomeBack returns ELIZA's greeting for name, returning to a session
+// whose last exchange was last.
+func welcomeBack(name string, last exchange) []string {
+	return []string{
+		fmt.Sprintf("Welcome back, %s.", name),
+		fmt.Sprintf("When we last spoke, you said %q, and I said %q", last.Sentence, last.Reply),
+		"Let's carry on from there.",
+	}
+}
+
+// goodbye reports whether sentence ends the conversation.
+func goodbye(sentence string) bool {
+	switch strings.Trim(strings.ToLower(sentence), " .!?") {
+	case "bye", "goodbye", "quit", "exit":
+		return true
+	}
+	return false
+}
+
+type keyword struct {
+	rank           int
+	decompositions []decomposition
+}
+
+type decomposition struct {
+	pattern     *regexp.Regexp
+	reassembly  []string
+	remembering bool // save a reassembly to memory instead of replying
+}
+
+func decompose(pattern string, reassembly ...string) decomposition {
+	return decomposition{
+		pattern:    regexp.MustCompile(`^` + pattern + `$`),
+		reassembly: reassembly,
+	}
+}
+
+func remember(pattern string, reassembly ...string) decomposition {
+	d := decompose(pattern, reassembly...)
+	d.remembering = true
+	return d
+}
+
+// keywords is an abridged DOCTOR script. Reassemblies may refer to the
+// pattern's first capture group, with its pronouns reflected, as %s.
+var keywords = map[string]keyword{
+	"sorry": {0, []decomposition{
+		decompose(`.*`, "Please don't apologize.", "Apologies are not necessary.", "What feelings do you have when you apologize?"),
+	}},
+	"remember": {5, []decomposition{
+		decompose(`.*\bi remember (.*)`, "Do you often think of %s?", "Does thinking of %s bring anything else to mind?", "Why do you remember %s just now?"),
+		decompose(`.*\bdo you remember (.*)`, "Did you think I would forget %s?", "Why do you think I should recall %s now?"),
+	}},
+	"dream": {3, []decomposition{
+		decompose(`.*`, "What does that dream suggest to you?", "Do you dream often?", "Do you believe that dreams have something to do with your problem?"),
+	}},
+	"hello": {0, []decomposition{
+		decompose(`.*`, "How do you do. Please state your problem.", "Hi. What seems to be your problem?"),
+	}},
+	"computer": {50, []decomposition{
+		decompose(`.*`, "Do computers worry you?", "Why do you mention computers?", "What do you think machines have to do with your problem?"),
+	}},
+	"my": {2, []decomposition{
+		remember(`.*\bmy (.*)`, "Let's discuss further why your %s.", "Earlier you said your %s.", "Does that have anything to do with the fact that your %s?"),
+		decompose(`.*\bmy (mother|father|sister|brother|wife|husband|children)\b.*`, "Tell me more about your family.", "Who else in your family concerns you?"),
+		decompose(`.*\bmy (.*)`, "Your %s?", "Why do you say your %s?", "Is it important to you that your %s?"),
+	}},
+	"i": {0, []decomposition{
+		decompose(`.*\bi am (.*)`, "Is it because you are %s that you came to me?", "How long have you been %s?", "Do you believe it is normal to be %s?"),
+		decompose(`.*\bi feel (.*)`, "Tell me more about such feelings.", "Do you often feel %s?", "Do you enjoy feeling %s?"),
+		decompose(`.*\bi want (.*)`, "What would it mean to you if you got %s?", "Why do you want %s?", "Suppose you got %s soon."),
+		decompose(`.*\bi (?:can't|cannot) (.*)`, "How do you know that you can't %s?", "Have you tried?", "Perhaps you could %s now."),
+		decompose(`.*\bi don't (.*)`, "Don't you really %s?", "Why don't you %s?", "Does that trouble you?"),
+		decompose(`.*\bi (.*) you\b.*`, "Perhaps in your fantasy we %s each other.", "Do you wish to %s me?"),
+		decompose(`.*`, "You say %s?", "Can you elaborate on that?", "Do you say that for some special reason?"),
+	}},
+	"you": {0, []decomposition{
+		decompose(`.*\byou are (.*)`, "What makes you think I am %s?", "Does it please you to believe I am %s?", "Perhaps you would like to be %s."),
+		decompose(`.*\bcan you (.*)`, "You believe I can %s, don't you?", "Perhaps you would like to be able to %s yourself."),
+		decompose(`.*\bwhy don't you (.*)`, "Do you believe I don't %s?", "Perhaps I will %s in good time."),
+		decompose(`.*`, "We were discussing you, not me.", "You're not really talking about me, are you?"),
+	}},
+	"because": {0, []decomposition{
+		decompose(`.*`, "Is that the real reason?", "Don't any other reasons come to mind?", "What other reasons might there be?"),
+	}},
+	"why": {0, []decomposition{
+		decompose(`.*`, "Why do you ask?", "Does that question interest you?", "What answer would please you most?"),
+	}},
+	"yes": {0, []decomposition{
+		decompose(`.*`, "You seem to be quite positive.", "You are sure.", "I see."),
+	}},
+	"no": {0, []decomposition{
+		decompose(`.*`, "Are you saying no just to be negative?", "You are being a bit negative.", "Why not?"),
+	}},
+	"always": {1, []decomposition{
+		decompose(`.*`, "Can you think of a specific example?", "When?", "Really, always?"),
+	}},
+	"everyone": {2, []decomposition{
+		decompose(`.*`, "Surely not everyone.", "Can you think of anyone in particular?", "Who, for example?"),
+	}},
+	"alike": {10, []decomposition{
+		decompose(`.*`, "In what way?", "What resemblance do you see?", "What does that similarity suggest to you?"),
+	}},
+}
+
+// synonyms map words to the keyword whose rules they share.
+var synonyms = map[string]string{
+	"dreamed":   "dream",
+	"dreams":    "dream",
+	"hi":        "hello",
+	"computers": "computer",
+	"machine":   "computer",
+	"machines":  "computer",
+	"apologize": "sorry",
+	"yeah":      "yes",
+	"nope":      "no",
+	"everybody": "everyone",
+	"nobody":    "everyone",
+	"like":      "alike",
+	"same":      "alike",
+}
+
+// fallbacks are used when no keyword matches and nothing is in memory.
+var fallbacks = []string{
+	"Please tell me more.",
+	"I'm not sure I understand you fully.",
+	"Please go on.",
+	"What does that suggest to you?",
+	"Do you feel strongly about discussing such things?",
+}
+
+// contractions are expanded before matching, so patterns only need to
+// handle the long forms.
+var contractions = strings.NewReplacer(
+	"i'm", "i am",
+	"you're", "you are",
+	"i've", "i have",
+	"you've", "you have",
+)
+
+// reflections swap first and second person when echoing the user's words.
+var reflections = map[string]string{
+	"am":       "are",
+	"are":      "am",
+	"i":        "you",
+	"me":       "you",
+	"my":       "your",
+	"mine":     "yours",
+	"myself":   "yourself",
+	"you":      "I",
+	"your":     "my",
+	"yours":    "mine",
+	"yourself": "myself",
+	"was":      "were",
+}
+
+var punctuation = regexp.MustCompile(`[^a-z0-9' ]+`)
+
+// reply returns ELIZA's response to sentence.
+func (d *doctor) reply(sentence string) string {
+	if goodbye(sentence) {
+		return "Goodbye. It was nice talking to you."
+	}
+	text := strings.ToLower(sentence)
+	text = punctuation.ReplaceAllString(text, " ")
+	text = contractions.Replace(strings.Join(strings.Fields(text), " "))
+
+	// Try keywords from highest to lowest rank; ties are broken by order
+	// of appearance.
+	var found []string
+	for _, word := range strings.Fields(text) {
+		if canonical, ok := synonyms[word]; ok {
+			word = canonical
+		}
+		if _, ok := keywords[word]; ok {
+			found = append(found, word)
+		}
+	}
+	for len(found) > 0 {
+		best := 0
+		for i, word := range found {
+			if keywords[word].rank > keywords[found[best]].rank {
+				best = i
+			}
+		}
+		word := found[best]
+		found = append(found[:best:best], found[best+1:]...)
+		if response, ok := d.apply(word, text); ok {
+			return response
+		}
+	}
+
+	if len(d.memory) > 0 {
+		response := d.memory[0]
+		d.memory = d.memory[1:]
+		return response
+	}
+	return d.next("", fallbacks)
+}
+
+// apply tries the decompositions of word against text, returning the first
+// reply.
+func (d *doctor) apply(word, text string) (string, bool) {
+	for i, decomp := range keywords[word].decompositions {
+		match := decomp.pattern.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		template := d.next(fmt.Sprintf("%s/%d", word, i), decomp.reassembly)
+		response := template
+		if strings.Contains(template, "%s") {
+			fragment := text
+			if len(match) > 1 {
+				fragment = match[1]
+			}
+			response = fmt.Sprintf(template, reflect(fragment))
+		}
+		if decomp.remembering {
+			d.memory = append(d.memory, response)
+			continue
+		}
+		return response, true
+	}
+	return "", false
+}
+
+// next returns the next of options for the rule named key.
+func (d *doctor) next(key string, options []string) string {
+	n := d.turns[key]
+	d.turns[key]++
+	return options[n%len(options)]
+}
+
+func reflect(fragment string) string {
+	words := strings.Fields(fragment)
+	for i, word := range words {
+		if reflected, ok := reflections[word]; ok {
+			words[i] = reflected
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestDoctorReplies(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		sentence string
+		want     string
+	}{
+		{"Hello there", "How do you do. Please state your problem."},
+		{"I am sad", "Is it because you are sad that you came to me?"},
+		{"I'm tired of my job", "Your job?"},
+		{"You are a machine", "Do computers worry you?"},
+		{"Men are all alike.", "In what way?"},
+		{"I remember my first bicycle", "Do you often think of your first bicycle?"},
+		{"sdfsdf", "Please tell me more."},
+		{"Goodbye!", "Goodbye. It was nice talking to you."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sentence, func(t *testing.T) {
+			t.Parallel()
+			attest.Equal(t, newDoctor().reply(tt.sentence), tt.want)
+		})
+	}
+}
+
+func TestDoctorCyclesReassemblies(t *testing.T) {
+	t.Parallel()
+
+	d := newDoctor()
+	attest.Equal(t, d.reply("I am sad"), "Is it because you are sad that you came to me?")
+	attest.Equal(t, d.reply("I am sad"), "How long have you been sad?")
+	attest.Equal(t, d.reply("I am sad"), "Do you believe it is normal to be sad?")
+	attest.Equal(t, d.reply("I am sad"), "Is it because you are sad that you came to me?")
+}
+
+func TestDoctorMemory(t *testing.T) {
+	t.Parallel()
+
+	d := newDoctor()
+	attest.Equal(t, d.reply("My boyfriend made me come here"), "Your boyfriend made you come here?")
+	// With no keyword to go on, ELIZA brings up what it remembered.
+	attest.Equal(t, d.reply("whatever"), "Let's discuss further why your boyfriend made you come here.")
+	attest.Equal(t, d.reply("whatever"), "Please tell me more.")
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
+	"charm.land/bubbles/v2/spinner"
+	tea "charm.land/bubbletea/v2"
+	"connectrpc.com/connect"
+	"go.akshayshah.org/memhttp"
+)
+
+// localEndpoint is the URL that stands for an in-process ELIZA server.
+const localEndpoint = "local"
+
+func runDuet(args []string) (err error) {
+	var (
+		opts       commonOptions
+		urls       [2]string
+		turns      int
+		opener     string
+		delay      time.Duration
+		transcript string
+	)
+	fs := flag.NewFlagSet("eliza duet", flag.ExitOnError)
+	opts.register(fs)
+	fs.StringVar(&urls[0], "a", localEndpoint, "base `URL` of the first ELIZA, or local for an in-process one")
+	fs.StringVar(&urls[1], "b", localEndpoint, "base `URL` of the second ELIZA, or local for an in-process one")
+	fs.IntVar(&turns, "turns", 20, "stop after this many replies")
+	fs.StringVar(&opener, "opener", "Hello. How are you feeling today?", "`sentence` the second ELIZA opens with")
+	fs.DurationVar(&delay, "delay", time.Second, "pause before each reply, so the dialogue can be followed")
+	fs.StringVar(&transcript, "transcript", "", "also write the dialogue to `path`")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if turns < 1 {
+		return errors.New("-turns must be at least 1")
+	}
+
+	interceptors, cleanup, err := opts.setup()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, cleanup())
+	}()
+
+	var sides [2]endpoint
+	for i, u := range urls {
+		e, closeEndpoint, err := newDuetEndpoint(u, connect.WithInterceptors(interceptors...))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = errors.Join(err, closeEndpoint())
+		}()
+		sides[i] = e
+	}
+
+	m := newDuetModel(sides, turns, opener, delay)
+	if transcript != "" {
+		f, err := os.Create(transcript)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = errors.Join(err, f.Close())
+		}()
+		m.transcript = f
+	}
+	_, err = tea.NewProgram(m).Run()
+	return err
+}
+
+// newDuetEndpoint returns an endpoint for rawURL, starting an in-process
+// server if it's [localEndpoint].
+func newDuetEndpoint(rawURL string, clientOpts ...connect.ClientOption) (endpoint, func() error, error) {
+	if rawURL != localEndpoint {
+		client, closeClient, err := newRemoteClient(rawURL, clientOpts...)
+		return endpoint{url: rawURL, client: client}, closeClient, err
+	}
+	server, err := memhttp.New(newServeMux(nil))
+	if err != nil {
+		return endpoint{}, nil, err
+	}
+	client := elizav1connect.NewElizaServiceClient(server.Client(), server.URL(), clientOpts...)
+	return endpoint{url: localEndpoint, client: client}, server.Close, nil
+}
+
+// duetModel lets two ELIZAs talk to each other: each reply is sent to the
+// other side as its next sentence.
+type duetModel struct {
+	sides         [2]endpoint
+	conversations [2]*connect.BidiStreamForClient[elizav1.ConverseRequest, elizav1.ConverseResponse]
+
+	maxTurns int
+	delay    time.Duration
+	lines    []duetLine
+	// seen holds every (sentence heard, reply) pair per side. A pair
+	// coming round again means the dialogue is going in circles.
+	seen    [2]map[[2]string]bool
+	stopped string // why the dialogue ended, if it has
+
+	transcript io.Writer
+	spinner    spinner.Model
+	height     int
+	err        error
+}
+
+type duetLine struct {
+	side     int
+	sentence string
+}
+
+// duetReplyMsg is a side's reply to the last line.
+type duetReplyMsg struct {
+	side     int
+	sentence string
+}
+
+func newDuetModel(sides [2]endpoint, maxTurns int, opener string, delay time.Duration) duetModel {
+	return duetModel{
+		sides: sides,
+		// Streams are only opened on the first Send.
+		conversations: [2]*connect.BidiStreamForClient[elizav1.ConverseRequest, elizav1.ConverseResponse]{
+			sides[0].client.Converse(context.Background()),
+			sides[1].client.Converse(context.Background()),
+		},
+		maxTurns: maxTurns,
+		delay:    delay,
+		// The second side opens, so the first one replies first.
+		lines:   []duetLine{{side: 1, sentence: opener}},
+		seen:    [2]map[[2]string]bool{make(map[[2]string]bool), make(map[[2]string]bool)},
+		spinner: spinner.New(),
+		height:  24,
+	}
+}
+
+func (m duetModel) Init() tea.Cmd {
+	m.record(m.lines[0])
+	return tea.Batch(m.spinner.Tick, m.converse(0, m.lines[0].sentence))
+}
+
+func (m duetModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.KeyPressMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc", "q":
+			m.closeConversations()
+			return m, tea.Quit
+		}
+		return m, nil
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		return m, nil
+	case spinner.TickMsg:
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	case errMsg:
+		slog.Error("duet request failed", errorAttrs(msg)...)
+		m.err = msg
+		m.stop("an error occurred")
+		return m, nil
+	case duetReplyMsg:
+		heard := m.lines[len(m.lines)-1].sentence
+		line := duetLine{side: msg.side, sentence: msg.sentence}
+		m.lines = append(m.lines, line)
+		m.record(line)
+		pair := [2]string{heard, msg.sentence}
+		switch {
+		case m.seen[msg.side][pair]:
+			m.stop("the dialogue is going in circles")
+			return m, nil
+		case m.turns() >= m.maxTurns:
+			m.stop(fmt.Sprintf("reached %d turns", m.maxTurns))
+			return m, nil
+		}
+		m.seen[msg.side][pair] = true
+		return m, m.converse(1-msg.side, msg.sentence)
+	}
+	return m, nil
+}
+
+// turns is the number of replies so far.
+func (m duetModel) turns() int {
+	return len(m.lines) - 1
+}
+
+// converse sends sentence to a side, and waits for its reply.
+func (m duetModel) converse(side int, sentence string) tea.Cmd {
+	conversation := m.conversations[side]
+	delay := m.delay
+	return func() tea.Msg {
+		time.Sleep(delay)
+		if err := conversation.Send(&elizav1.ConverseRequest{Sentence: sentence}); err != nil {
+			return errMsg(err)
+		}
+		res, err := conversation.Receive()
+		if err != nil {
+			return errMsg(err)
+		}
+		return duetReplyMsg{side: side, sentence: res.Sentence}
+	}
+}
+
+// stop ends the dialogue, recording why.
+func (m *duetModel) stop(reason string) {
+	m.stopped = reason
+	m.closeConversations()
+	if m.transcript != nil {
+		fmt.Fprintf(m.transcript, "-- stopped after %d turns: %s\n", m.turns(), reason)
+	}
+}
+
+func (m *duetModel) closeConversations() {
+	for i, conversation := range m.conversations {
+		if conversation != nil {
+			_ = conversation.CloseRequest()
+			_ = conversation.CloseResponse()
+			m.conversations[i] = nil
+		}
+	}
+}
+
+func (m duetModel) record(line duetLine) {
+	if m.transcript != nil {
+		fmt.Fprintf(m.transcript, "%s: %s\n", m.speaker(line.side), line.sentence)
+	}
+}
+
+func (m duetModel) speaker(side int) string {
+	return fmt.Sprintf("Eliza %c (%s)", 'A'+side, m.sides[side].url)
+}
+
+func (m duetModel) View() tea.View {
+	var footer string
+	switch {
+	case m.err != nil:
+		footer = fmt.Sprintf("Stopped: %s: %s. Press q to quit.", m.stopped, m.err)
+	case m.stopped != "":
+		footer = fmt.Sprintf("Stopped after %d turns: %s. Press q to quit.", m.turns(), m.stopped)
+	default:
+		next := 1 - m.lines[len(m.lines)-1].side
+		footer = fmt.Sprintf("%s: %s", m.speaker(next), m.spinner.View())
+	}
+
+	lines := make([]string, 0, len(m.lines)+2)
+	for _, line := range m.lines {
+		lines = append(lines, fmt.Sprintf("%s: %s", m.speaker(line.side), line.sentence))
+	}
+	lines = append(lines, "", footer)
+	// Keep the latest lines on screen.
+	if len(lines) > m.height && m.height > 0 {
+		lines = lines[len(lines)-m.height:]
+	}
+	return tea.NewView(strings.Join(lines, "\n"))
+}
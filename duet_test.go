@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
+	tea "charm.land/bubbletea/v2"
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp"
+)
+
+// parrotHandler replies to every sentence with the same one.
+type parrotHandler struct {
+	elizav1connect.UnimplementedElizaServiceHandler
+}
+
+func (parrotHandler) Converse(
+	ctx context.Context,
+	stream *connect.BidiStream[elizav1.ConverseRequest, elizav1.ConverseResponse],
+) error {
+	for {
+		if _, err := stream.Receive(); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := stream.Send(&elizav1.ConverseResponse{Sentence: "Go on."}); err != nil {
+			return err
+		}
+	}
+}
+
+// runDuetModel drives m until the dialogue stops.
+func runDuetModel(t *testing.T, m duetModel) duetModel {
+	t.Helper()
+
+	cmd := m.converse(0, m.lines[0].sentence)
+	for m.stopped == "" {
+		attest.True(t, cmd != nil, attest.Fatal())
+		next, nextCmd := m.Update(cmd())
+		m, cmd = next.(duetModel), nextCmd
+	}
+	return m
+}
+
+func TestDuetStopsAtTurnLimit(t *testing.T) {
+	t.Parallel()
+
+	local := endpoint{url: localEndpoint, client: startLocalServer(t)}
+	m := newDuetModel([2]endpoint{local, local}, 4, "I am sad", 0)
+	var transcript strings.Builder
+	m.transcript = &transcript
+
+	m = runDuetModel(t, m)
+	attest.Equal(t, m.turns(), 4)
+	attest.Equal(t, m.stopped, "reached 4 turns")
+	attest.Equal(t, m.lines[1].sentence, "Is it because you are sad that you came to me?")
+	// Each reply goes to the other side.
+	for i, line := range m.lines {
+		attest.Equal(t, line.side, (i+1)%2)
+	}
+	attest.Subsequence(t, transcript.String(), "Eliza A (local): Is it because you are sad that you came to me?\n")
+	attest.Subsequence(t, transcript.String(), "-- stopped after 4 turns: reached 4 turns\n")
+	attest.Subsequence(t, m.View().Content, "Stopped after 4 turns")
+}
+
+func TestDuetDetectsLoops(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.Handle(elizav1connect.NewElizaServiceHandler(parrotHandler{}))
+	server, err := memhttp.New(mux)
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() {
+		attest.Ok(t, server.Close())
+	})
+	parrot := endpoint{
+		url:    "parrot",
+		client: elizav1connect.NewElizaServiceClient(server.Client(), server.URL()),
+	}
+
+	m := runDuetModel(t, newDuetModel([2]endpoint{parrot, parrot}, 100, "Hello.", 0))
+	// A hears "Go on." and answers "Go on." on turn 2; B does the same on
+	// turn 3, and A repeats itself on turn 4.
+	attest.Equal(t, m.turns(), 4)
+	attest.Equal(t, m.stopped, "the dialogue is going in circles")
+}
+
+func TestDuetQuits(t *testing.T) {
+	t.Parallel()
+
+	local := endpoint{url: localEndpoint, client: startLocalServer(t)}
+	m := newDuetModel([2]endpoint{local, local}, 4, "Hello.", 0)
+	next, cmd := m.Update(tea.KeyPressMsg{Code: 'q', Text: "q"})
+	attest.True(t, cmd != nil)
+	attest.Equal(t, next.(duetModel).conversations[0], nil)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	"connectrpc.com/connect"
+	"github.com/bufbuild/httplb"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// healthServiceName is the fully-qualified name of the gRPC health
+	// service.
+	healthServiceName = "grpc.health.v1.Health"
+	// healthCheckProcedure is the path of the health service's Check RPC.
+	healthCheckProcedure = "/" + healthServiceName + "/Check"
+	// healthWatchProcedure is the path of the health service's Watch RPC.
+	healthWatchProcedure = "/" + healthServiceName + "/Watch"
+)
+
+// newHealthHandler returns the path and handler for the gRPC health
+// service, reporting the local ELIZA service as serving. Since that never
+// changes while the server's up, Watch sends the status once and then holds
+// the stream open until the client goes.
+func newHealthHandler(opts ...connect.HandlerOption) (string, http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle(healthCheckProcedure, connect.NewUnaryHandler(
+		healthCheckProcedure,
+		func(ctx context.Context, req *connect.Request[healthv1.HealthCheckRequest]) (*connect.Response[healthv1.HealthCheckResponse], error) {
+			status := healthStatus(req.Msg.Service)
+			if status == healthv1.HealthCheckResponse_SERVICE_UNKNOWN {
+				return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("unknown service %q", req.Msg.Service))
+			}
+			return connect.NewResponse(&healthv1.HealthCheckResponse{Status: status}), nil
+		},
+		opts...,
+	))
+	mux.Handle(healthWatchProcedure, connect.NewServerStreamHandler(
+		healthWatchProcedure,
+		func(ctx context.Context, req *connect.Request[healthv1.HealthCheckRequest], stream *connect.ServerStream[healthv1.HealthCheckResponse]) error {
+			// Unknown services aren't an error here: they might
+			// be registered later, so they're reported instead.
+			if err := stream.Send(&healthv1.HealthCheckResponse{Status: healthStatus(req.Msg.Service)}); err != nil {
+				return err
+			}
+			<-ctx.Done()
+			return nil
+		},
+		opts...,
+	))
+	return "/" + healthServiceName + "/", mux
+}
+
+// healthStatus returns the health of the named service.
+func healthStatus(service string) healthv1.HealthCheckResponse_ServingStatus {
+	switch service {
+	// The empty name stands for the whole server.
+	case "", elizav1connect.ElizaServiceName:
+		return healthv1.HealthCheckResponse_SERVING
+	}
+	return healthv1.HealthCheckResponse_SERVICE_UNKNOWN
+}
+
+// checkHealth asks the gRPC health service at baseURL whether the ELIZA
+// service is serving, returning an error if it isn't or the check fails.
+func checkHealth(ctx context.Context, httpClient connect.HTTPClient, baseURL string, clientOpts ...connect.ClientOption) error {
+	client := connect.NewClient[healthv1.HealthCheckRequest, healthv1.HealthCheckResponse](
+		httpClient, baseURL+healthCheckProcedure, clientOpts...,
+	)
+	res, err := client.CallUnary(ctx, connect.NewRequest(&healthv1.HealthCheckRequest{
+		Service: elizav1connect.ElizaServiceName,
+	}))
+	if err != nil {
+		return fmt.Errorf("health check of %s: %w", baseURL, err)
+	}
+	if status := res.Msg.Status; status != healthv1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("health check of %s: ELIZA service is %s", baseURL, status)
+	}
+	slog.Info("health check passed", slog.String("url", baseURL))
+	return nil
+}
+
+// checkRemoteHealth runs [checkHealth] against each of urls, giving up on
+// each after a few seconds.
+func checkRemoteHealth(urls []string, clientOpts ...connect.ClientOption) (err error) {
+	httpClient := httplb.NewClient()
+	defer func() {
+		err = errors.Join(err, httpClient.Close())
+	}()
+	for _, u := range urls {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := checkHealth(ctx, httpClient, u, clientOpts...)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
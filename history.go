@@ -0,0 +1,165 @@
+package main
+
+import (
+	"strings"
+
+	"charm.land/bubbles/v2/viewport"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// An entry is a line of the conversation shown in a tab.
+type entry struct {
+	speaker   string // empty for blank lines and separators
+	text      string
+	separator bool
+	thinking  bool // ELIZA hasn't replied yet
+	// remark is the index of the entry's remark in the log, or -1 for
+	// blank lines and ELIZA thinking.
+	remark int
+	status remarkStatus
+}
+
+// entries returns the lines of the active tab's conversation, in the order
+// they're shown: the log, with queued sentences last.
+func (m model) entries() []entry {
+	var (
+		entries      []entry
+		introduction bool // the last line was part of an introduction
+	)
+	for i, r := range m.remarks {
+		if r.status == statusPending {
+			continue
+		}
+		if introduction && r.kind != kindIntroduction {
+			entries = append(entries, entry{remark: -1})
+		}
+		introduction = r.kind == kindIntroduction
+		if r.kind == kindRename {
+			entries = append(entries, entry{text: r.text, separator: true, remark: i}, entry{remark: -1})
+			continue
+		}
+		entries = append(entries, entry{speaker: r.speaker, text: r.text, remark: i, status: r.status})
+	}
+	if introduction {
+		entries = append(entries, entry{remark: -1})
+	}
+	if m.waitingForResponse {
+		entries = append(entries, entry{speaker: "Eliza", thinking: true, remark: -1})
+	}
+	for i, r := range m.remarks {
+		if r.status == statusPending {
+			entries = append(entries, entry{speaker: r.speaker, text: r.text, remark: i, status: r.status})
+		}
+	}
+	return entries
+}
+
+// A history is the conversation rendered as rows on the screen.
+type history struct {
+	rows      []string
+	entryRows []int // the first row of each entry
+	matchRows []int // the row of each search match
+}
+
+// historyRows renders entries as screen rows, wrapped to the width of the
+// terminal, with search matches and the selected entry highlighted.
+func (m model) historyRows(entries []entry, matches []match) history {
+	current := m.currentMatch(matches)
+	var h history
+	next := 0 // the first match not yet rendered
+	for i, e := range entries {
+		h.entryRows = append(h.entryRows, len(h.rows))
+		var line string
+		switch {
+		case e.separator:
+			line = faintStyle.Render("-- " + e.text + " --")
+		case e.thinking:
+			line = e.speaker + ": " + m.spinner.View()
+		case e.speaker != "":
+			prefix := e.speaker + ": "
+			var text strings.Builder
+			last := 0
+			for ; next < len(matches) && matches[next].entry == i; next++ {
+				mt := matches[next]
+				h.matchRows = append(h.matchRows, len(h.rows)+len(m.wrap(prefix+e.text[:mt.start]))-1)
+				style := matchStyle
+				if next == current {
+					style = currentMatchStyle
+				}
+				text.WriteString(e.text[last:mt.start])
+				text.WriteString(style.Render(e.text[mt.start:mt.end]))
+				last = mt.end
+			}
+			text.WriteString(e.text[last:])
+			line = prefix + text.String()
+			if e.status != "" && e.status != statusSent {
+				line += faintStyle.Render(" (" + string(e.status) + ")")
+			}
+			if m.selecting && i == m.selected {
+				line = selectedStyle.Render(line)
+			}
+		}
+		h.rows = append(h.rows, m.wrap(line)...)
+	}
+	return h
+}
+
+// wrap splits line into rows that fit the terminal.
+func (m model) wrap(line string) []string {
+	if m.width <= 0 {
+		return []string{line}
+	}
+	return strings.Split(ansi.Wrap(line, m.width, ""), "\n")
+}
+
+// historyHeight returns how many rows the conversation history can take up,
+// with reserved rows taken by everything else, or zero if there's no limit.
+func (m model) historyHeight(reserved int) int {
+	if m.height <= 0 {
+		return 0
+	}
+	// Leave a row for the input.
+	return max(1, m.height-reserved-1)
+}
+
+// scrolled returns the rows that fit in height, scrolled up from the bottom
+// by m.scroll.
+func (m model) scrolled(rows []string, height int) []string {
+	if height == 0 || len(rows) <= height {
+		return rows
+	}
+	vp := viewport.New(viewport.WithWidth(m.width), viewport.WithHeight(height))
+	vp.SetContentLines(rows)
+	vp.SetYOffset(len(rows) - height - min(m.scroll, len(rows)-height))
+	return strings.Split(vp.View(), "\n")
+}
+
+// scrollBy scrolls the conversation up by n rows, or down if n is negative.
+func (m model) scrollBy(n int) model {
+	rows := m.historyRows(m.entries(), nil).rows
+	height := m.historyHeight(m.reservedRows())
+	m.scroll = max(0, min(m.scroll+n, len(rows)-height))
+	return m
+}
+
+// scrollTo scrolls the conversation so that row is in the middle.
+func (m model) scrollTo(row, rowCount int) model {
+	height := m.historyHeight(m.reservedRows())
+	if height == 0 {
+		return m
+	}
+	top := max(0, min(row-height/2, rowCount-height))
+	m.scroll = max(0, rowCount-height-top)
+	return m
+}
+
+// reservedRows is the number of rows taken by everything but the history and
+// the input.
+func (m model) reservedRows() int {
+	return strings.Count(m.tabBar()+m.footer(), "\n")
+}
+
+// footer renders what's shown below the input.
+func (m model) footer() string {
+	return m.notice.view() + m.retryView() + m.search.view(m)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"charm.land/bubbles/v2/help"
+	"charm.land/bubbles/v2/key"
+)
+
+// keyMap holds the TUI's key bindings.
+type keyMap struct {
+	send     key.Binding
+	complete key.Binding
+	newTab   key.Binding
+	nextTab  key.Binding
+	prevTab  key.Binding
+	help     key.Binding
+	quit     key.Binding
+
+	scrollUp    key.Binding
+	scrollDown  key.Binding
+	search      key.Binding
+	nextMatch   key.Binding
+	prevMatch   key.Binding
+	toggleRegex key.Binding
+	toggleCase  key.Binding
+	selectMode  key.Binding
+	selectPrev  key.Binding
+	selectNext  key.Binding
+	copy        key.Binding
+	copyAll     key.Binding
+	editQueued  key.Binding
+	dropQueued  key.Binding
+}
+
+// The key map presets, by name.
+var keyPresets = map[string]func() keyMap{
+	"default": defaultKeyMap,
+	"vim":     vimKeyMap,
+	"emacs":   emacsKeyMap,
+}
+
+// defaultKeyMap puts help on f1, which doesn't type anything, so that a
+// sentence can start with a question mark.
+func defaultKeyMap() keyMap {
+	return keyMap{
+		send:     key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "send")),
+		complete: key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "complete command")),
+		newTab:   key.NewBinding(key.WithKeys("ctrl+t"), key.WithHelp("ctrl+t", "new tab")),
+		nextTab:  key.NewBinding(key.WithKeys("ctrl+tab"), key.WithHelp("ctrl+tab", "next tab")),
+		prevTab:  key.NewBinding(key.WithKeys("ctrl+shift+tab"), key.WithHelp("ctrl+shift+tab", "previous tab")),
+		help:     key.NewBinding(key.WithKeys("f1"), key.WithHelp("f1", "toggle help")),
+		quit:     key.NewBinding(key.WithKeys("ctrl+c", "esc"), key.WithHelp("ctrl+c/esc", "quit")),
+
+		scrollUp:    key.NewBinding(key.WithKeys("pgup"), key.WithHelp("pgup", "scroll up")),
+		scrollDown:  key.NewBinding(key.WithKeys("pgdown"), key.WithHelp("pgdown", "scroll down")),
+		search:      key.NewBinding(key.WithKeys("ctrl+f"), key.WithHelp("ctrl+f", "search")),
+		nextMatch:   key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "next match")),
+		prevMatch:   key.NewBinding(key.WithKeys("N"), key.WithHelp("N", "previous match")),
+		toggleRegex: key.NewBinding(key.WithKeys("alt+r"), key.WithHelp("alt+r", "toggle regex")),
+		toggleCase:  key.NewBinding(key.WithKeys("alt+c"), key.WithHelp("alt+c", "toggle match case")),
+		selectMode:  key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("ctrl+s", "select messages")),
+		selectPrev:  key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "previous message")),
+		selectNext:  key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "next message")),
+		copy:        key.NewBinding(key.WithKeys("y", "enter"), key.WithHelp("y/enter", "copy message")),
+		copyAll:     key.NewBinding(key.WithKeys("Y"), key.WithHelp("Y", "copy transcript")),
+		editQueued:  key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "edit queued sentence")),
+		dropQueued:  key.NewBinding(key.WithKeys("d", "delete"), key.WithHelp("d/delete", "delete queued sentence")),
+	}
+}
+
+// vimKeyMap leaves esc alone, since it's pressed out of habit, and scrolls
+// and moves between tabs with alt and the vim motions. It's only a nod to
+// vim: there are no modes, and the other keys are the default ones.
+func vimKeyMap() keyMap {
+	keys := defaultKeyMap()
+	keys.scrollUp = rebind(keys.scrollUp, "pgup", "alt+k")
+	keys.scrollDown = rebind(keys.scrollDown, "pgdown", "alt+j")
+	keys.nextTab = rebind(keys.nextTab, "alt+l", "ctrl+tab")
+	keys.prevTab = rebind(keys.prevTab, "alt+h", "ctrl+shift+tab")
+	keys.quit = rebind(keys.quit, "ctrl+c")
+	return keys
+}
+
+// emacsKeyMap moves between tabs with alt+n and alt+p, and completes with
+// alt+/ too. Like vimKeyMap, it leaves the other keys as they are.
+func emacsKeyMap() keyMap {
+	keys := defaultKeyMap()
+	keys.complete = rebind(keys.complete, "tab", "alt+/")
+	keys.nextTab = rebind(keys.nextTab, "alt+n", "ctrl+tab")
+	keys.prevTab = rebind(keys.prevTab, "alt+p", "ctrl+shift+tab")
+	keys.quit = rebind(keys.quit, "ctrl+c")
+	return keys
+}
+
+// rebind returns b bound to keys instead, with the same description.
+func rebind(b key.Binding, keys ...string) key.Binding {
+	b.SetKeys(keys...)
+	b.SetHelp(strings.Join(keys, "/"), b.Help().Desc)
+	return b
+}
+
+// newKeyMap returns the preset with the given name, with the keys of some
+// actions replaced. Actions are named as in [keyMap.actions].
+func newKeyMap(preset string, bindings map[string][]string) (keyMap, error) {
+	if preset == "" {
+		preset = "default"
+	}
+	newPreset, ok := keyPresets[preset]
+	if !ok {
+		return keyMap{}, fmt.Errorf("unknown key preset %q: must be default, vim or emacs", preset)
+	}
+	keys := newPreset()
+	actions := keys.actions()
+	for _, action := range slices.Sorted(maps.Keys(bindings)) {
+		b, ok := actions[action]
+		if !ok {
+			return keyMap{}, fmt.Errorf("unknown key action %q", action)
+		}
+		if len(bindings[action]) == 0 {
+			return keyMap{}, fmt.Errorf("no keys for action %q", action)
+		}
+		*b = rebind(*b, bindings[action]...)
+	}
+	return keys, nil
+}
+
+// actions returns the bindings in k by action name.
+func (k *keyMap) actions() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"send":     &k.send,
+		"complete": &k.complete,
+		"new-tab":  &k.newTab,
+		"next-tab": &k.nextTab,
+		"prev-tab": &k.prevTab,
+		"help":     &k.help,
+		"quit":     &k.quit,
+
+		"scroll-up":    &k.scrollUp,
+		"scroll-down":  &k.scrollDown,
+		"search":       &k.search,
+		"next-match":   &k.nextMatch,
+		"prev-match":   &k.prevMatch,
+		"toggle-regex": &k.toggleRegex,
+		"toggle-case":  &k.toggleCase,
+		"select":       &k.selectMode,
+		"select-prev":  &k.selectPrev,
+		"select-next":  &k.selectNext,
+		"copy":         &k.copy,
+		"copy-all":     &k.copyAll,
+		"edit-queued":  &k.editQueued,
+		"drop-queued":  &k.dropQueued,
+	}
+}
+
+// helpView renders groups of bindings as columns, for the help overlay that
+// toggle closes. Disabled bindings are left out.
+func helpView(toggle key.Binding, groups [][]key.Binding) string {
+	h := help.New()
+	var b strings.Builder
+	b.WriteString("Keys\n\n")
+	b.WriteString(h.FullHelpView(groups))
+	b.WriteString("\n\n")
+	b.WriteString(faintStyle.Render(fmt.Sprintf("Press %s or esc to close.", toggle.Help().Key)))
+	return b.String()
+}
+
+// fullHelp returns the bindings that apply to m's current view.
+func (m model) fullHelp() [][]key.Binding {
+	keys := m.keys
+	if m.waitingForResponse {
+		keys.send.SetHelp(keys.send.Help().Key, "queue")
+	}
+	keys.complete.SetEnabled(!m.waitingForResponse)
+	keys.nextTab.SetEnabled(len(m.tabs) > 1)
+	keys.prevTab.SetEnabled(len(m.tabs) > 1)
+	if m.selecting {
+		keys.selectMode.SetHelp(keys.selectMode.Help().Key, "stop selecting")
+		queued := m.selectedPending(m.entries()) >= 0
+		keys.editQueued.SetEnabled(queued)
+		keys.dropQueued.SetEnabled(queued)
+		return [][]key.Binding{
+			{keys.selectPrev, keys.selectNext, keys.selectMode},
+			{keys.copy, keys.copyAll, keys.editQueued, keys.dropQueued},
+			{keys.scrollUp, keys.scrollDown},
+			{keys.help, keys.quit},
+		}
+	}
+	if m.search.active {
+		// Help can't be shown while the pattern's being typed, so
+		// this is browsing the matches.
+		keys.search.SetHelp(keys.search.Help().Key, "edit search")
+		return [][]key.Binding{
+			{keys.search, keys.nextMatch, keys.prevMatch},
+			{keys.toggleRegex, keys.toggleCase},
+			{keys.scrollUp, keys.scrollDown},
+			{keys.help, keys.quit},
+		}
+	}
+	return [][]key.Binding{
+		{keys.send, keys.complete},
+		{keys.newTab, keys.nextTab, keys.prevTab},
+		{keys.scrollUp, keys.scrollDown, keys.search, keys.selectMode},
+		{keys.help, keys.quit},
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"go.akshayshah.org/attest"
+)
+
+func TestNewKeyMap(t *testing.T) {
+	t.Parallel()
+
+	keys, err := newKeyMap("", nil)
+	attest.Ok(t, err)
+	attest.Equal(t, keys.quit.Keys(), []string{"ctrl+c", "esc"})
+
+	keys, err = newKeyMap("vim", map[string][]string{"new-tab": {"alt+t"}})
+	attest.Ok(t, err)
+	attest.Equal(t, keys.quit.Keys(), []string{"ctrl+c"})
+	attest.Equal(t, keys.nextTab.Keys(), []string{"alt+l", "ctrl+tab"})
+	attest.Equal(t, keys.newTab.Keys(), []string{"alt+t"})
+	attest.Equal(t, keys.newTab.Help().Key, "alt+t")
+	attest.Equal(t, keys.newTab.Help().Desc, "new tab")
+
+	_, err = newKeyMap("nano", nil)
+	attest.Error(t, err)
+	_, err = newKeyMap("emacs", map[string][]string{"jump": {"ctrl+j"}})
+	attest.Error(t, err)
+	_, err = newKeyMap("emacs", map[string][]string{"quit": {}})
+	attest.Error(t, err)
+}
+
+func TestHelpOverlay(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel(startFakeServer(t))
+	press := func(m model, k tea.KeyPressMsg) (model, tea.Cmd) {
+		next, cmd := m.Update(k)
+		return next.(model), cmd
+	}
+	f1 := tea.KeyPressMsg{Code: tea.KeyF1}
+
+	// ? starts a sentence, even with nothing typed.
+	m, _ = press(m, tea.KeyPressMsg{Code: '?', Text: "?"})
+	attest.False(t, m.showHelp)
+	attest.Equal(t, m.textInput.Value(), "?")
+
+	m, _ = press(m, f1)
+	attest.True(t, m.showHelp)
+	view := m.View().Content
+	attest.Subsequence(t, view, "ctrl+t")
+	attest.Subsequence(t, view, "new tab")
+	// With one tab, there's nothing to switch to.
+	attest.False(t, strings.Contains(view, "next tab"))
+
+	// Esc closes the overlay rather than quitting.
+	m, cmd := press(m, tea.KeyPressMsg{Code: tea.KeyEscape})
+	attest.False(t, m.showHelp)
+	attest.Equal(t, cmd, nil)
+	attest.Equal(t, m.textInput.Value(), "?")
+}
+
+func TestConfiguredKeys(t *testing.T) {
+	t.Parallel()
+
+	keys, err := newKeyMap("vim", map[string][]string{"new-tab": {"alt+t"}})
+	attest.Ok(t, err)
+	m := initialModel(startFakeServer(t)).withKeys(keys)
+
+	next, _ := m.Update(tea.KeyPressMsg{Code: 't', Mod: tea.ModAlt})
+	m = next.(model)
+	attest.Equal(t, len(m.tabs), 2)
+	next, _ = m.Update(tea.KeyPressMsg{Code: 'h', Mod: tea.ModAlt})
+	m = next.(model)
+	attest.Equal(t, m.active, 0)
+
+	// The vim preset doesn't quit on esc.
+	_, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	if cmd != nil {
+		_, quit := cmd().(tea.QuitMsg)
+		attest.False(t, quit)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/grpcreflect"
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// streamRetryDelay is how long a client over its stream limit is told to
+// wait, since there's no telling when one of its streams will close.
+const streamRetryDelay = time.Second
+
+// limitInterceptor stops any one client from starving the others. Each
+// client, identified by its IP address, has a token bucket that every call,
+// and every message received on a stream, takes from; and a cap on how many
+// bidi streams, such as Converse, it may have open at once. Calls over the
+// limits fail with [connect.CodeResourceExhausted], with a RetryInfo detail
+// and a Retry-After header saying when to try again. The health and
+// reflection services are exempt, so that probes and tools like grpcurl
+// neither fail for a busy client nor lock it out of ELIZA.
+type limitInterceptor struct {
+	rate       rate.Limit
+	burst      int
+	maxStreams int
+
+	mu      sync.Mutex
+	clients map[string]*clientLimits
+}
+
+// clientLimits is what a [limitInterceptor] tracks for one client.
+type clientLimits struct {
+	limiter *rate.Limiter
+	streams int
+}
+
+// maxIdleClients is how many clients are tracked before the idle ones are
+// forgotten.
+const maxIdleClients = 1024
+
+// newLimitInterceptor returns an interceptor for handlers that allows each
+// client perSecond calls and messages a second, in bursts of up to burst,
+// and maxStreams concurrent bidi streams. A perSecond or maxStreams of zero
+// means no limit.
+func newLimitInterceptor(perSecond float64, burst, maxStreams int) *limitInterceptor {
+	limit := rate.Limit(perSecond)
+	if perSecond <= 0 {
+		limit = rate.Inf
+	}
+	return &limitInterceptor{
+		rate:       limit,
+		burst:      max(1, burst),
+		maxStreams: maxStreams,
+		clients:    make(map[string]*clientLimits),
+	}
+}
+
+func (i *limitInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient || exemptFromLimits(req.Spec().Procedure) {
+			return next(ctx, req)
+		}
+		if err := i.take(clientKey(req.Peer())); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+func (i *limitInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *limitInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		if exemptFromLimits(conn.Spec().Procedure) {
+			return next(ctx, conn)
+		}
+		key := clientKey(conn.Peer())
+		if err := i.take(key); err != nil {
+			return err
+		}
+		if conn.Spec().StreamType != connect.StreamTypeBidi {
+			return next(ctx, conn)
+		}
+		if err := i.openStream(key); err != nil {
+			return err
+		}
+		defer i.closeStream(key)
+		return next(ctx, &limitedHandlerConn{StreamingHandlerConn: conn, limits: i, key: key})
+	}
+}
+
+// exemptFromLimits reports whether procedure belongs to the health or
+// reflection services, which no limits apply to.
+func exemptFromLimits(procedure string) bool {
+	for _, service := range []string{healthServiceName, grpcreflect.ReflectV1ServiceName, grpcreflect.ReflectV1AlphaServiceName} {
+		if strings.HasPrefix(procedure, "/"+service+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedHandlerConn takes from the client's token bucket for every message
+// it receives.
+type limitedHandlerConn struct {
+	connect.StreamingHandlerConn
+
+	limits *limitInterceptor
+	key    string
+}
+
+func (c *limitedHandlerConn) Receive(msg any) error {
+	if err := c.StreamingHandlerConn.Receive(msg); err != nil {
+		return err
+	}
+	return c.limits.take(c.key)
+}
+
+// clientKey identifies the client at peer. Connections from the same host
+// share limits, whatever their port.
+func clientKey(peer connect.Peer) string {
+	if host, _, err := net.SplitHostPort(peer.Addr); err == nil {
+		return host
+	}
+	return peer.Addr
+}
+
+// client returns the limits for the client with key, which must be called
+// with mu held.
+func (i *limitInterceptor) client(key string) *clientLimits {
+	c, ok := i.clients[key]
+	if ok {
+		return c
+	}
+	if len(i.clients) >= maxIdleClients {
+		i.forgetIdle()
+	}
+	c = &clientLimits{limiter: rate.NewLimiter(i.rate, i.burst)}
+	i.clients[key] = c
+	return c
+}
+
+// forgetIdle forgets clients with a full bucket and no open streams, whose
+// limits are the same as a new client's. It must be called with mu held.
+func (i *limitInterceptor) forgetIdle() {
+	for key, c := range i.clients {
+		if c.streams == 0 && c.limiter.Tokens() >= float64(i.burst) {
+			delete(i.clients, key)
+		}
+	}
+}
+
+// take takes a token from the client's bucket, or returns an error if it's
+// empty.
+func (i *limitInterceptor) take(key string) error {
+	if i.rate == rate.Inf {
+		return nil
+	}
+	i.mu.Lock()
+	r := i.client(key).limiter.Reserve()
+	i.mu.Unlock()
+	if delay := r.Delay(); delay > 0 {
+		r.Cancel()
+		return resourceExhausted(fmt.Sprintf("rate limit of %g per second exceeded", float64(i.rate)), delay)
+	}
+	return nil
+}
+
+// openStream counts a new stream for the client, or returns an error if it
+// already has as many as it's allowed.
+func (i *limitInterceptor) openStream(key string) error {
+	if i.maxStreams <= 0 {
+		return nil
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	c := i.client(key)
+	if c.streams >= i.maxStreams {
+		return resourceExhausted(fmt.Sprintf("limit of %d concurrent streams reached", i.maxStreams), streamRetryDelay)
+	}
+	c.streams++
+	return nil
+}
+
+func (i *limitInterceptor) closeStream(key string) {
+	if i.maxStreams <= 0 {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.clients[key].streams--
+}
+
+// resourceExhausted returns a ResourceExhausted error telling the client to
+// retry after delay.
+func resourceExhausted(msg string, delay time.Duration) error {
+	err := connect.NewError(connect.CodeResourceExhausted, errors.New(msg))
+	if detail, detailErr := connect.NewErrorDetail(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(delay),
+	}); detailErr == nil {
+		err.AddDetail(detail)
+	}
+	err.Meta().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+	return err
+}
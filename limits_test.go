@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
+	"connectrpc.com/connect"
+	"connectrpc.com/grpcreflect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// startLimitedServer starts the local ELIZA service in memory behind
+// limits, and returns a client for it.
+func startLimitedServer(t *testing.T, limits *limitInterceptor) (*memhttp.Server, elizav1connect.ElizaServiceClient) {
+	t.Helper()
+
+	server, err := memhttp.New(newServeMux(nil, connect.WithInterceptors(limits)))
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() {
+		attest.Ok(t, server.Close())
+	})
+	return server, elizav1connect.NewElizaServiceClient(server.Client(), server.URL())
+}
+
+// assertRetryable asserts that err is ResourceExhausted, saying when to
+// retry.
+func assertRetryable(t *testing.T, err error) {
+	t.Helper()
+
+	var connectErr *connect.Error
+	attest.True(t, errors.As(err, &connectErr), attest.Fatal(), attest.Sprintf("got %v", err))
+	attest.Equal(t, connectErr.Code(), connect.CodeResourceExhausted)
+	attest.NotZero(t, connectErr.Meta().Get("Retry-After"))
+	attest.Equal(t, len(connectErr.Details()), 1, attest.Fatal())
+	detail, err := connectErr.Details()[0].Value()
+	attest.Ok(t, err, attest.Fatal())
+	info, ok := detail.(*errdetails.RetryInfo)
+	attest.True(t, ok, attest.Fatal())
+	attest.True(t, info.RetryDelay.AsDuration() > 0)
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	server, client := startLimitedServer(t, newLimitInterceptor(0.1, 2, 0))
+	ctx := context.Background()
+	say := func() error {
+		_, err := client.Say(ctx, connect.NewRequest(&elizav1.SayRequest{Sentence: "hello"}))
+		return err
+	}
+	attest.Ok(t, say())
+	attest.Ok(t, say())
+	assertRetryable(t, say())
+	// Health checks don't count.
+	attest.Ok(t, checkHealth(ctx, server.Client(), server.URL()))
+
+	// Sentences sent on a stream count too: opening it takes one token,
+	// and the first sentence the other.
+	_, client = startLimitedServer(t, newLimitInterceptor(0.1, 2, 0))
+	stream := client.Converse(ctx)
+	attest.Ok(t, stream.Send(&elizav1.ConverseRequest{Sentence: "hello"}))
+	_, err := stream.Receive()
+	attest.Ok(t, err)
+	attest.Ok(t, stream.Send(&elizav1.ConverseRequest{Sentence: "hello again"}))
+	_, err = stream.Receive()
+	assertRetryable(t, err)
+	attest.Ok(t, stream.CloseRequest())
+	attest.Ok(t, stream.CloseResponse())
+}
+
+func TestStreamLimit(t *testing.T) {
+	t.Parallel()
+
+	_, client := startLimitedServer(t, newLimitInterceptor(0, 0, 1))
+	ctx := context.Background()
+	open := func() (*connect.BidiStreamForClient[elizav1.ConverseRequest, elizav1.ConverseResponse], error) {
+		stream := client.Converse(ctx)
+		attest.Ok(t, stream.Send(&elizav1.ConverseRequest{Sentence: "hello"}))
+		_, err := stream.Receive()
+		return stream, err
+	}
+	closeStream := func(stream *connect.BidiStreamForClient[elizav1.ConverseRequest, elizav1.ConverseResponse]) {
+		attest.Ok(t, stream.CloseRequest())
+		_, err := stream.Receive()
+		attest.ErrorIs(t, err, io.EOF)
+		attest.Ok(t, stream.CloseResponse())
+	}
+
+	first, err := open()
+	attest.Ok(t, err)
+	second, err := open()
+	assertRetryable(t, err)
+	attest.Ok(t, second.CloseResponse())
+
+	// Once the first stream's closed, there's room for another.
+	closeStream(first)
+	third, err := open()
+	attest.Ok(t, err)
+	closeStream(third)
+}
+
+func TestHealthAndReflectionAreNotLimited(t *testing.T) {
+	t.Parallel()
+
+	server, client := startLimitedServer(t, newLimitInterceptor(0.1, 2, 1))
+	ctx := t.Context()
+
+	// A health watcher and a grpcurl session hold streams open...
+	watch := connect.NewClient[healthv1.HealthCheckRequest, healthv1.HealthCheckResponse](
+		server.Client(), server.URL()+healthWatchProcedure, connect.WithGRPC(),
+	)
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	updates, err := watch.CallServerStream(watchCtx, connect.NewRequest(&healthv1.HealthCheckRequest{}))
+	attest.Ok(t, err, attest.Fatal())
+	attest.True(t, updates.Receive(), attest.Sprintf("no status: %v", updates.Err()))
+	reflection := grpcreflect.NewClient(server.Client(), server.URL()).NewStream(ctx)
+	_, err = reflection.ListServices()
+	attest.Ok(t, err)
+	_, err = reflection.ListServices()
+	attest.Ok(t, err)
+
+	// ...without taking the client's tokens or its one stream.
+	stream := client.Converse(ctx)
+	attest.Ok(t, stream.Send(&elizav1.ConverseRequest{Sentence: "hello"}))
+	_, err = stream.Receive()
+	attest.Ok(t, err)
+	attest.Ok(t, stream.CloseRequest())
+	attest.Ok(t, stream.CloseResponse())
+
+	_, err = reflection.Close()
+	attest.Ok(t, err)
+	cancel()
+	attest.Ok(t, updates.Close())
+}
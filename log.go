@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// setupLogging installs the default [slog.Logger]. Stdout belongs to the TUI,
+// so logs are only written when file is non-empty; otherwise they're
+// discarded.
+//
+// The returned function closes the log file.
+func setupLogging(file, level, format string) (func() error, error) {
+	logger, closeFn, err := newLogger(file, level, format)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	return closeFn, nil
+}
+
+func newLogger(file, level, format string) (*slog.Logger, func() error, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, nil, fmt.Errorf("invalid log level %q", level)
+	}
+	if format != "text" && format != "json" {
+		return nil, nil, fmt.Errorf("invalid log format %q: must be text or json", format)
+	}
+	if file == "" {
+		return slog.New(slog.DiscardHandler), func() error { return nil }, nil
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return slog.New(newLogHandler(f, lvl, format)), f.Close, nil
+}
+
+func newLogHandler(w io.Writer, level slog.Level, format string) slog.Handler {
+	options := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		return slog.NewJSONHandler(w, options)
+	}
+	return slog.NewTextHandler(w, options)
+}
+
+// errorAttrs returns log attributes describing err, including its Connect
+// code.
+func errorAttrs(err error) []any {
+	return []any{
+		slog.String("code", connect.CodeOf(err).String()),
+		slog.Any("error", err),
+	}
+}
+
+// newLoggingInterceptor returns an interceptor that logs the start and end of
+// every RPC, and the opening and closing of every stream, to logger. It can be
+// used on both clients and handlers.
+func newLoggingInterceptor(logger *slog.Logger) connect.Interceptor {
+	return loggingInterceptor{logger: logger}
+}
+
+type loggingInterceptor struct {
+	logger *slog.Logger
+}
+
+func (i loggingInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		start := time.Now()
+		attrs := []any{
+			slog.String("procedure", req.Spec().Procedure),
+			slog.String("peer", req.Peer().Addr),
+		}
+		i.logger.DebugContext(ctx, "rpc started", attrs...)
+		res, err := next(ctx, req)
+		attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+		if err != nil {
+			i.logger.ErrorContext(ctx, "rpc failed", append(attrs, errorAttrs(err)...)...)
+		} else {
+			i.logger.DebugContext(ctx, "rpc finished", attrs...)
+		}
+		return res, err
+	}
+}
+
+func (i loggingInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		i.logger.DebugContext(ctx, "stream opened",
+			slog.String("procedure", spec.Procedure),
+			slog.String("peer", conn.Peer().Addr),
+		)
+		return &loggingClientConn{
+			StreamingClientConn: conn,
+			ctx:                 ctx,
+			logger:              i.logger,
+			start:               time.Now(),
+		}
+	}
+}
+
+func (i loggingInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		start := time.Now()
+		attrs := []any{
+			slog.String("procedure", conn.Spec().Procedure),
+			slog.String("peer", conn.Peer().Addr),
+		}
+		i.logger.DebugContext(ctx, "stream opened", attrs...)
+		err := next(ctx, conn)
+		attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+		if err != nil {
+			i.logger.ErrorContext(ctx, "stream failed", append(attrs, errorAttrs(err)...)...)
+		} else {
+			i.logger.DebugContext(ctx, "stream closed", attrs...)
+		}
+		return err
+	}
+}
+
+// loggingClientConn logs stream errors, other than end of stream, and the
+// closing of the stream.
+type loggingClientConn struct {
+	connect.StreamingClientConn
+
+	ctx    context.Context
+	logger *slog.Logger
+	start  time.Time
+}
+
+func (c *loggingClientConn) Send(msg any) error {
+	err := c.StreamingClientConn.Send(msg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		c.logger.ErrorContext(c.ctx, "stream send failed", append(c.attrs(), errorAttrs(err)...)...)
+	}
+	return err
+}
+
+func (c *loggingClientConn) Receive(msg any) error {
+	err := c.StreamingClientConn.Receive(msg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		c.logger.ErrorContext(c.ctx, "stream receive failed", append(c.attrs(), errorAttrs(err)...)...)
+	}
+	return err
+}
+
+func (c *loggingClientConn) CloseResponse() error {
+	err := c.StreamingClientConn.CloseResponse()
+	c.logger.DebugContext(c.ctx, "stream closed",
+		append(c.attrs(), slog.Duration("duration", time.Since(c.start)))...,
+	)
+	return err
+}
+
+func (c *loggingClientConn) attrs() []any {
+	return []any{
+		slog.String("procedure", c.Spec().Procedure),
+		slog.String("peer", c.Peer().Addr),
+	}
+}
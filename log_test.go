@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp"
+)
+
+func TestNewLoggerValidatesFlags(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := newLogger("", "loud", "text")
+	attest.Error(t, err)
+	_, _, err = newLogger("", "info", "xml")
+	attest.Error(t, err)
+
+	logger, closeLog, err := newLogger(filepath.Join(t.TempDir(), "eliza.log"), "warn", "json")
+	attest.Ok(t, err)
+	attest.False(t, logger.Enabled(t.Context(), slog.LevelInfo))
+	attest.True(t, logger.Enabled(t.Context(), slog.LevelWarn))
+	attest.Ok(t, closeLog())
+}
+
+func TestLoggingInterceptorLogsErrorCodes(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(newLogHandler(&buf, slog.LevelDebug, "json"))
+
+	mux := http.NewServeMux()
+	mux.Handle(elizav1connect.NewElizaServiceHandler(&fakeElizaServiceErrorHandler{}))
+	server, err := memhttp.New(mux)
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() {
+		attest.Ok(t, server.Close())
+	})
+	client := elizav1connect.NewElizaServiceClient(
+		server.Client(),
+		"https://example.com",
+		connect.WithInterceptors(newLoggingInterceptor(logger)),
+	)
+
+	m := initialModel(client)
+	_, ok := m.introduce("User")().(errMsg)
+	attest.True(t, ok)
+
+	var messages []string
+	var failure map[string]any
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var record map[string]any
+		attest.Ok(t, decoder.Decode(&record), attest.Fatal())
+		messages = append(messages, record["msg"].(string))
+		if record["msg"] == "stream receive failed" {
+			failure = record
+		}
+	}
+	attest.Equal(t, messages, []string{"stream opened", "stream receive failed", "stream closed"})
+	attest.Equal(t, failure["code"], any("unknown"))
+	attest.Equal(t, failure["procedure"], any(elizav1connect.ElizaServiceIntroduceProcedure))
+}
@@ -3,15 +3,131 @@ Eliza interacts with the [Connect ELIZA demo service].
 
 Usage:
 
-	eliza
+	eliza [flags]
+	eliza -compare [flags] urlA urlB
+	eliza serve [flags]
+	eliza bench [flags]
+	eliza duet [flags]
+	eliza web [flags]
+	eliza rpc introduce|say|converse [flags]
+
+Without a command, eliza runs a TUI for talking to ELIZA. The commands are:
+
+	serve
+		Serve a local implementation of the ELIZA service, with gRPC
+		health checking and server reflection. Calls with the same
+		Eliza-Session-Id header share ELIZA's memory, so a client that
+		reconnects carries on where it left off. Sessions are kept in
+		memory, or with -sessions path, in a file, until they've gone
+		unused for -session-ttl.
+		-rate, -burst and -max-streams limit each client's calls and
+		open streams, by IP address; calls over the limits fail with
+		ResourceExhausted, saying when to retry.
+	bench
+		Load test an ELIZA service.
+	duet
+		Let two ELIZAs, remote or local, talk to each other.
+	web
+		Serve a chat page, for talking to ELIZA from a browser.
+	rpc introduce -name name
+	rpc say sentence
+	rpc converse
+		Call an RPC directly and print the responses as JSON, one per
+		line. converse reads a JSON ConverseRequest from each line of
+		stdin, sending them all over one stream. With -v, the response
+		headers and trailers are printed to stderr.
+
+Run a command with -h for its flags.
+
+In the TUI, ctrl+t opens a new tab with its own conversation, and ctrl+tab and
+ctrl+shift+tab switch between tabs. Input starting with a slash is a command
+rather than a sentence for ELIZA: /help lists them, and tab completes them.
+f1 shows every key binding. pgup and pgdown scroll the
+conversation, and ctrl+f searches it: enter finishes typing the pattern, n and
+N move between matches, alt+r and alt+c toggle regular expressions and case
+sensitivity, and esc ends the search. ctrl+s selects messages: up and down
+move between them, y copies one and Y copies the whole transcript, through
+the terminal (with OSC 52) and any local clipboard utility. Sentences sent
+while waiting for ELIZA are queued, and sent in order as her replies arrive;
+until then, selecting one and pressing e takes it back to edit, and d cancels
+it. When the service is overloaded or unavailable, the TUI counts down to the
+time it asks for (or backs off) and resends, up to five times. A sentence
+ELIZA can't reply to is marked failed, and the conversation carries on.
+/export json writes the whole log, with each line's status, timing and
+service. The TUI's flags are:
+
+	-url url
+		Base URL of the ELIZA service (default https://demo.connectrpc.com).
+		Use the h2c scheme for a local server started by eliza serve.
+		Repeat to talk to several services: new tabs cycle through them.
+	-config path
+		Read configuration from path, a JSON file (default
+		$XDG_CONFIG_HOME/eliza/config.json, or the platform's equivalent).
+		It can pick a key binding preset (default, vim or emacs) and
+		rebind actions. The vim and emacs presets only change scrolling,
+		switching tabs, completion and quitting; the other keys are the
+		default ones:
+
+			{"keys": {"preset": "vim", "bindings": {"new-tab": ["alt+t"]}}}
+
+		The actions are send, complete, new-tab, next-tab, prev-tab, help,
+		quit, scroll-up, scroll-down, search, next-match, prev-match,
+		toggle-regex, toggle-case, select, select-prev, select-next, copy,
+		copy-all, edit-queued and drop-queued.
+
+		When one of ELIZA's replies arrives while the terminal is
+		unfocused, the TUI rings the bell and asks for a desktop
+		notification (with OSC 9 and OSC 777), unless turned off:
+
+			{"notify": {"bell": false, "desktop": false, "command": "..."}}
+
+		The command is run like -notify's, which overrides it.
+	-log-file path
+		Write logs to path. Logs are discarded if unset.
+	-log-level level
+		Minimum level to log: debug, info, warn or error (default info).
+	-log-format format
+		Format of log lines: text or json (default text).
+	-telemetry-file path
+		Write OpenTelemetry spans and metrics to path as JSON.
+	-accessible
+		Print each line of the conversation once, as it happens, with
+		announcements such as "ELIZA is thinking…", instead of redrawing
+		the conversation with a spinner. This suits screen readers.
+		Search and selection are off: the terminal's scrollback has the
+		conversation.
+	-notify command
+		While the terminal is unfocused, run command with the shell for
+		each of ELIZA's replies, with the reply on its stdin.
+	-compare
+		Send each sentence to the two ELIZA services given as arguments, and
+		show their replies side by side with the differences highlighted.
+	-record path
+		Record every Introduce and Converse exchange to the cassette at path.
+	-replay path
+		Replay the cassette at path from an in-memory server, instead of
+		connecting to the demo service.
+	-health-check
+		Before starting, ask each service's gRPC health service (as served
+		by eliza serve) whether ELIZA is serving, and exit if it isn't.
+
+Every command accepts -log-file, -log-level, -log-format and -telemetry-file.
+Without -telemetry-file, spans and metrics are exported over OTLP when the
+standard OTEL_* environment variables (such as OTEL_EXPORTER_OTLP_ENDPOINT or
+OTEL_TRACES_EXPORTER) are set.
 
 [Connect ELIZA demo service]: https://connectrpc.com/demo/
 */
 package main
 
 import (
+	"cmp"
 	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"strings"
 	"time"
@@ -20,121 +136,562 @@ import (
 	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
 	"connectrpc.com/connect"
 	"github.com/bufbuild/httplb"
+	"charm.land/bubbles/v2/key"
 	"charm.land/bubbles/v2/spinner"
 	"charm.land/bubbles/v2/textinput"
 	tea "charm.land/bubbletea/v2"
+	"go.opentelemetry.io/otel"
 )
 
 func main() {
-	client := httplb.NewClient()
-	defer client.Close()
-
-	if _, err := tea.NewProgram(
-		initialModel(
-			elizav1connect.NewElizaServiceClient(
-				client,
-				"https://demo.connectrpc.com",
-			),
-		),
-	).Run(); err != nil {
+	if err := run(os.Args[1:]); err != nil {
+		slog.Error("exiting", errorAttrs(err)...)
 		fmt.Printf("error: %s\n", err)
 		os.Exit(1)
 	}
 }
 
+// defaultURL is the ELIZA service used when -url isn't set.
+const defaultURL = "https://demo.connectrpc.com"
+
+// commands are the subcommands, keyed by name. Without a subcommand, eliza
+// runs the TUI.
+var commands = map[string]func(args []string) error{
+	"serve": runServe,
+	"bench": runBench,
+	"duet":  runDuet,
+	"web":   runWeb,
+	"rpc":   runRPC,
+}
+
+func run(args []string) error {
+	if len(args) > 0 {
+		if command, ok := commands[args[0]]; ok {
+			return command(args[1:])
+		}
+	}
+	return runTUI(args)
+}
+
+// commonOptions are the flags shared by every command.
+type commonOptions struct {
+	logFile       string
+	logLevel      string
+	logFormat     string
+	telemetryFile string
+}
+
+func (o *commonOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.logFile, "log-file", "", "write logs to `path`")
+	fs.StringVar(&o.logLevel, "log-level", "info", "minimum `level` to log: debug, info, warn or error")
+	fs.StringVar(&o.logFormat, "log-format", "text", "`format` of log lines: text or json")
+	fs.StringVar(&o.telemetryFile, "telemetry-file", "", "write OpenTelemetry spans and metrics to `path`")
+}
+
+// setup configures logging and telemetry. It returns the interceptors that
+// every client and handler should use, and a function that flushes and
+// closes the logs and telemetry.
+func (o *commonOptions) setup() ([]connect.Interceptor, func() error, error) {
+	closeLog, err := setupLogging(o.logFile, o.logLevel, o.logFormat)
+	if err != nil {
+		return nil, nil, err
+	}
+	shutdownTelemetry, err := setupTelemetry(context.Background(), o.telemetryFile)
+	if err != nil {
+		return nil, nil, errors.Join(err, closeLog())
+	}
+	cleanup := func() error {
+		return errors.Join(shutdownTelemetry(context.Background()), closeLog())
+	}
+	interceptors, err := newTelemetryInterceptors(otel.GetTracerProvider(), otel.GetMeterProvider())
+	if err != nil {
+		return nil, nil, errors.Join(err, cleanup())
+	}
+	return append(interceptors, newLoggingInterceptor(slog.Default())), cleanup, nil
+}
+
+// options are the TUI's command-line flags.
+type options struct {
+	commonOptions
+
+	urls        stringsFlag
+	configFile  string
+	compare     bool
+	notify      string
+	accessible  bool
+	recordFile  string
+	replayFile  string
+	healthCheck bool
+}
+
+func runTUI(args []string) (err error) {
+	var opts options
+	fs := flag.NewFlagSet("eliza", flag.ExitOnError)
+	opts.register(fs)
+	fs.Var(&opts.urls, "url", "base `URL` of an ELIZA service; repeat to give new tabs different services (default "+defaultURL+")")
+	fs.StringVar(&opts.configFile, "config", "", "read configuration from `path` (default "+defaultConfigPath()+")")
+	fs.BoolVar(&opts.compare, "compare", false, "compare the replies of the two ELIZA services given as arguments")
+	fs.BoolVar(&opts.accessible, "accessible", false, "print the conversation a line at a time, for screen readers")
+	fs.StringVar(&opts.notify, "notify", "", "run `command` with each reply on stdin while the terminal is unfocused")
+	fs.StringVar(&opts.recordFile, "record", "", "record exchanges to the cassette at `path`")
+	fs.StringVar(&opts.replayFile, "replay", "", "replay the cassette at `path` instead of connecting to the ELIZA service")
+	fs.BoolVar(&opts.healthCheck, "health-check", false, "check that each ELIZA service is healthy before starting")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.recordFile != "" && opts.replayFile != "" {
+		return errors.New("-record and -replay are mutually exclusive")
+	}
+	if opts.healthCheck && opts.replayFile != "" {
+		return errors.New("-health-check and -replay are mutually exclusive")
+	}
+	if opts.compare {
+		if opts.accessible {
+			return errors.New("-compare and -accessible are mutually exclusive")
+		}
+		if fs.NArg() != 2 || len(opts.urls) > 0 || opts.replayFile != "" {
+			return errors.New("-compare takes exactly two URLs as arguments, and no -url or -replay")
+		}
+		opts.urls = fs.Args()
+	}
+	if len(opts.urls) == 0 {
+		opts.urls = stringsFlag{defaultURL}
+	}
+	cfg, err := loadConfig(cmp.Or(opts.configFile, defaultConfigPath()), opts.configFile == "")
+	if err != nil {
+		return err
+	}
+	keys, err := cfg.keyMap()
+	if err != nil {
+		return err
+	}
+
+	interceptors, cleanup, err := opts.setup()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, cleanup())
+	}()
+	if opts.recordFile != "" {
+		rec := &recorder{}
+		interceptors = append(interceptors, rec)
+		defer func() {
+			err = errors.Join(err, rec.save(opts.recordFile))
+		}()
+	}
+
+	if opts.healthCheck {
+		if err := checkRemoteHealth(opts.urls, connect.WithInterceptors(interceptors...)); err != nil {
+			return err
+		}
+	}
+	endpoints, closeEndpoints, err := newEndpoints(opts, connect.WithInterceptors(interceptors...))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, closeEndpoints())
+	}()
+
+	var m tea.Model
+	if opts.compare {
+		compare := newCompareModel(endpoints[0], endpoints[1])
+		compare.keys = keys
+		m = compare
+	} else {
+		tui := initialModelWithEndpoints(endpoints).withKeys(keys)
+		tui.clipboard = localClipboard()
+		tui.notifier = cfg.notifier(opts.notify)
+		tui.accessible = opts.accessible
+		if opts.replayFile == "" {
+			var closers []func() error
+			defer func() {
+				for _, closeClient := range closers {
+					err = errors.Join(err, closeClient())
+				}
+			}()
+			tui.dial = func(u string) (endpoint, error) {
+				client, closeClient, err := newRemoteClient(u, connect.WithInterceptors(interceptors...))
+				if err != nil {
+					return endpoint{}, err
+				}
+				closers = append(closers, closeClient)
+				return endpoint{url: u, client: client}, nil
+			}
+		}
+		m = tui
+	}
+	var program *tea.Program
+	if tui, ok := m.(model); ok {
+		tui.program = postFunc(func(msg tea.Msg) {
+			program.Send(msg)
+		})
+		m = tui
+	}
+	program = tea.NewProgram(m)
+	final, err := program.Run()
+	if tui, ok := final.(model); ok {
+		// Let the servers end their streams, so that nothing's cut
+		// off, such as the streams' telemetry.
+		tui.waitForConversations()
+	}
+	return err
+}
+
+// newEndpoints returns a client for each ELIZA service or, if -replay is
+// set, a single client for an in-memory server replaying the cassette. The
+// returned function releases the clients' resources.
+func newEndpoints(opts options, clientOpts ...connect.ClientOption) ([]endpoint, func() error, error) {
+	if opts.replayFile != "" {
+		c, err := loadCassette(opts.replayFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		slog.Info("replaying cassette", slog.String("path", opts.replayFile))
+		client, closeClient, err := newReplayServer(c, clientOpts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []endpoint{{url: opts.replayFile, client: client}}, closeClient, nil
+	}
+
+	var (
+		endpoints []endpoint
+		closers   []func() error
+	)
+	closeAll := func() error {
+		var err error
+		for _, closeFn := range closers {
+			err = errors.Join(err, closeFn())
+		}
+		return err
+	}
+	for _, u := range opts.urls {
+		client, closeClient, err := newRemoteClient(u, clientOpts...)
+		if err != nil {
+			return nil, nil, errors.Join(err, closeAll())
+		}
+		endpoints = append(endpoints, endpoint{url: u, client: client})
+		closers = append(closers, closeClient)
+	}
+	return endpoints, closeAll, nil
+}
+
+// stringsFlag is a flag that may be repeated.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// newRemoteClient returns a client for the ELIZA service at baseURL. Use the
+// h2c scheme for a local server without TLS, such as one started by
+// eliza serve. The returned function releases the client's resources.
+func newRemoteClient(baseURL string, clientOpts ...connect.ClientOption) (elizav1connect.ElizaServiceClient, func() error, error) {
+	httpClient := httplb.NewClient()
+	slog.Info("client created", slog.String("url", baseURL))
+	return elizav1connect.NewElizaServiceClient(httpClient, baseURL, clientOpts...), func() error {
+		defer slog.Info("client closed", slog.String("url", baseURL))
+		return httpClient.Close()
+	}, nil
+}
+
 type introductionMsg []string
-type sayMsg string
 type errMsg error
 
+// sayMsg is a reply from ELIZA, and when it was received.
+type sayMsg struct {
+	sentence string
+	received time.Time
+}
+
+// sayFailedMsg reports that the last sentence sent got no reply, because of
+// err. Unlike an errMsg, it doesn't end the program.
+type sayFailedMsg struct {
+	err error
+}
+
 type model struct {
+	// session is the conversation in the active tab. Its entry in tabs
+	// is stale until another tab is switched to.
+	session
+	tabs      []session
+	active    int
+	endpoints []endpoint
+	nextID    int
+	// dial returns an endpoint for a URL given to /endpoint. If it's nil,
+	// only the endpoints given on the command line can be used.
+	dial func(url string) (endpoint, error)
+
+	keys     keyMap
+	showHelp bool
+	search   searchState
+	// selecting is set while choosing a message to copy, the entry at
+	// selected.
+	selecting bool
+	selected  int
+	// background is set while a message for another tab's session is
+	// applied, so that the active tab's selection is left alone.
+	background bool
+	// clipboard copies to the local clipboard, if there's a utility for
+	// it.
+	clipboard func(text string) error
+	// notifier tells the user about replies while unfocused is set,
+	// which it is between the terminal reporting losing and regaining
+	// focus.
+	notifier  notifier
+	unfocused bool
+	// accessible prints the conversation a line at a time, for screen
+	// readers, instead of redrawing it.
+	accessible bool
+	// program is sent ELIZA's replies as they're received, from outside
+	// the event loop.
+	program poster
+	spinner spinner.Model
+	// width and height are the size of the terminal, or zero if it's
+	// unknown.
+	width, height int
+
+	err error
+}
+
+// A session is a single conversation with ELIZA, shown in its own tab.
+type session struct {
+	id     int
 	client elizav1connect.ElizaServiceClient
+	url    string
+	// sessionID is sent with every call, so that a service that keeps
+	// sessions, such as eliza serve, remembers the conversation across
+	// streams.
+	sessionID string
 
 	hasIntroduced      bool
 	waitingForResponse bool
+	mode               string // bidiMode or unaryMode
 
-	conversation *connect.BidiStreamForClient[elizav1.ConverseRequest, elizav1.ConverseResponse]
+	conversation *conversation
+	streams      int // how many Converse streams have been opened
 
-	name                 string
-	introductionReceived []string
-	said                 []string
-	sayResponses         []string
+	name string
+	// remarks is the conversation log, including the sentences queued
+	// while waiting for ELIZA.
+	remarks []remark
+	// sentAt is when the request being waited for was sent.
+	sentAt time.Time
 
 	textInput textinput.Model
-	spinner   spinner.Model
+	notice    notice
+	// scroll is how many rows the conversation is scrolled up from the
+	// bottom.
+	scroll int
 
-	err error
+	// retry is set while waiting to resend a request that the server
+	// turned away, and retries counts the retries in a row.
+	retry   *pendingRetry
+	retries int
+
+	// In accessible mode, these are what's been announced, so that it's
+	// only announced once.
+	thinkingAnnounced bool
+	retryAnnounced    *pendingRetry
+	noticeAnnounced   notice
 }
 
 func initialModel(client elizav1connect.ElizaServiceClient) model {
+	return initialModelWithEndpoints([]endpoint{{client: client}})
+}
+
+// initialModelWithEndpoints returns a model whose first tab talks to the
+// first endpoint. New tabs cycle through the rest.
+func initialModelWithEndpoints(endpoints []endpoint) model {
+	m := model{
+		endpoints: endpoints,
+		spinner:   spinner.New(),
+	}
+	m.session = newSession(m.nextID, endpoints[0])
+	m.textInput.SetSuggestions(m.completions())
+	m.tabs = []session{m.session}
+	m.nextID++
+	return m.withKeys(defaultKeyMap())
+}
+
+// withKeys returns m with its key bindings replaced.
+func (m model) withKeys(keys keyMap) model {
+	m.keys = keys
+	m.textInput.KeyMap.AcceptSuggestion = keys.complete
+	m.tabs[m.active] = m.session
+	return m
+}
+
+func newSession(id int, e endpoint) session {
 	textInput := textinput.New()
 	textInput.Placeholder = "Joseph Weizenbaum"
 	textInput.CharLimit = 156
 	textInput.SetWidth(50)
+	textInput.ShowSuggestions = true
 	textInput.Focus()
 
-	return model{
-		client:    client,
+	return session{
+		id:        id,
+		client:    e.client,
+		url:       e.url,
+		sessionID: rand.Text(),
+		mode:      bidiMode,
 		textInput: textInput,
-		spinner:   spinner.New(),
 	}
 }
 
 func (m model) Init() tea.Cmd {
+	if m.accessible {
+		// The terminal's cursor is used, and nothing is animated.
+		return nil
+	}
 	return tea.Batch(textinput.Blink, m.spinner.Tick)
 }
 
+// Update handles msg, then in accessible mode, prints what's new.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	if tui, ok := next.(model); ok && tui.accessible {
+		tui, announce := tui.announce()
+		return tui, tea.Batch(cmd, announce)
+	}
+	return next, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
 	switch msg := msg.(type) {
 	case tea.KeyPressMsg:
-		switch msg.String() {
-		case "enter":
-			if m.waitingForResponse {
-				// Already waiting on ELIZA; ignore until the
-				// response arrives.
+		if m.showHelp {
+			if key.Matches(msg, m.keys.help) || msg.String() == "esc" {
+				m.showHelp = false
+			} else if key.Matches(msg, m.keys.quit) {
+				m.closeConversations()
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		if m.search.active {
+			if next, cmd, ok := m.updateSearch(msg); ok {
+				return next, cmd
+			}
+		}
+		if m.selecting {
+			if next, cmd, ok := m.updateSelection(msg); ok {
+				return next, cmd
+			}
+		}
+		switch {
+		case key.Matches(msg, m.keys.send):
+			if m.search.active || m.selecting {
+				return m, nil
+			}
+			if m.waitingForResponse && !m.hasIntroduced {
+				// The input's hidden until ELIZA has introduced
+				// herself.
 				return m, nil
 			}
 			text := m.textInput.Value()
 			if text == "" {
 				return m, nil
 			}
-			m.waitingForResponse = true
+			if name, _, ok := parseCommand(text); ok && m.waitingForResponse && waitsForReply(name) {
+				m.notice = failure("Wait for ELIZA to reply before running /%s.", name)
+				return m, nil
+			}
 			m.textInput.Reset()
+			m.notice = notice{}
+			if _, _, ok := parseCommand(text); ok {
+				// Clear the completion of the command just run.
+				m.textInput.SetSuggestions(m.completions())
+				return m.runCommand(text)
+			}
+			text = strings.TrimPrefix(text, "/")
+			if m.waitingForResponse {
+				m.addSentence(text, statusPending)
+				return m, nil
+			}
+			m.waitingForResponse = true
 			if !m.hasIntroduced {
 				m.name = text
 				m.textInput.Placeholder = ""
-				return m, m.introduce(text)
+				return m.sendIntroduction(text)
 			}
-			m.said = append(m.said, text)
-			if m.conversation == nil {
-				// Open the bidi stream once, on first use; it is
-				// reused for the rest of the conversation.
-				m.conversation = m.client.Converse(context.Background())
-			}
-			return m, m.say(text)
-		case "ctrl+c", "esc":
-			m.closeConversation()
+			m.addSentence(text, statusSent)
+			return m.send(text)
+		case key.Matches(msg, m.keys.help):
+			m.showHelp = true
+			return m, nil
+		case key.Matches(msg, m.keys.search) && m.hasIntroduced && !m.accessible:
+			return m.openSearch(""), nil
+		case key.Matches(msg, m.keys.selectMode) && m.hasIntroduced && !m.accessible:
+			return m.startSelecting(), nil
+		case key.Matches(msg, m.keys.scrollUp):
+			return m.scrollBy(max(1, m.historyHeight(m.reservedRows())-1)), nil
+		case key.Matches(msg, m.keys.scrollDown):
+			return m.scrollBy(-max(1, m.historyHeight(m.reservedRows())-1)), nil
+		case key.Matches(msg, m.keys.newTab):
+			return m.openTab()
+		case key.Matches(msg, m.keys.nextTab):
+			return m.switchTo((m.active + 1) % len(m.tabs)), nil
+		case key.Matches(msg, m.keys.prevTab):
+			return m.switchTo((m.active + len(m.tabs) - 1) % len(m.tabs)), nil
+		case key.Matches(msg, m.keys.quit):
+			m.closeConversations()
 			return m, tea.Quit
 		default:
 			m.textInput, cmd = m.textInput.Update(msg)
 			return m, cmd
 		}
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m, nil
+	case tea.FocusMsg:
+		return m.updateFocus(true)
+	case tea.BlurMsg:
+		return m.updateFocus(false)
 	case errMsg:
+		slog.Error("request failed", errorAttrs(msg)...)
 		m.err = msg
-		m.closeConversation()
+		m.closeConversations()
 		return m, tea.Quit
+	case sessionMsg:
+		return m.updateSession(msg)
+	case streamMsg:
+		return m.updateStream(msg)
 	case spinner.TickMsg:
 		m.spinner, cmd = m.spinner.Update(msg)
 		return m, cmd
+	case retryMsg:
+		return m.scheduleRetry(msg)
+	case retryTickMsg:
+		return m.updateRetry()
 	case introductionMsg:
 		m.hasIntroduced = true
+		m.retries = 0
+		// Add the introduction while still waiting, so that it's
+		// timed.
+		m.addIntroduction(msg)
 		m.waitingForResponse = false
-		m.introductionReceived = msg
-		return m, nil
+		return m.sendQueued()
 	case sayMsg:
+		m.retries = 0
+		m.addFromEliza(kindReply, cmp.Or(msg.received, time.Now()), msg.sentence)
 		m.waitingForResponse = false
-		m.sayResponses = append(m.sayResponses, string(msg))
+		next, cmd := m.sendQueued()
+		return next, tea.Batch(m.notifyReply(msg.sentence), cmd)
+	case sayFailedMsg:
+		return m.sayFailed(msg.err)
+	case noticeMsg:
+		m.notice = notice(msg)
 		return m, nil
 	default:
 		m.textInput, cmd = m.textInput.Update(msg)
@@ -144,12 +701,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m model) View() tea.View {
 	v := tea.NewView("")
+	v.ReportFocus = true
 	if m.err != nil {
 		v.SetContent(fmt.Sprintf("An error occurred: %s", m.err))
+	} else if m.showHelp {
+		v.SetContent(m.tabBar() + helpView(m.keys.help, m.fullHelp()))
+	} else if m.accessible {
+		content, cursor := m.accessibleView()
+		v.SetContent(content)
+		v.Cursor = cursor
 	} else if !m.hasIntroduced {
-		v.SetContent(m.introductionView())
+		v.SetContent(m.tabBar() + m.introductionView() + m.notice.view() + m.retryView())
 	} else {
-		v.SetContent(m.conversationView())
+		v.SetContent(m.tabBar() + m.conversationView() + m.footer())
 	}
 	return v
 }
@@ -169,47 +733,26 @@ func (m model) introductionView() string {
 
 func (m model) conversationView() string {
 	var conversation strings.Builder
-	// Write introduction
-	for _, introductionLine := range m.introductionReceived {
-		conversation.WriteString("Eliza: ")
-		conversation.WriteString(introductionLine)
-		conversation.WriteString("\n")
-	}
-	conversation.WriteString("\n")
-	// Write conversation
-	for i := 0; i < len(m.said); i++ {
-		// Things we've said
-		conversation.WriteString(m.name)
-		conversation.WriteString(": ")
-		conversation.WriteString(m.said[i])
-		conversation.WriteString("\n")
-		// Things Eliza has said
-		conversation.WriteString("Eliza: ")
-		// If this is the last thing Eliza has said and we're waiting for a
-		// response, show the spinner.
-		// Otherwise, show the response.
-		if i == len(m.said)-1 && m.waitingForResponse {
-			conversation.WriteString(m.spinner.View())
-		} else {
-			conversation.WriteString(m.sayResponses[i])
-		}
+	entries := m.entries()
+	matches, _ := m.matches(entries)
+	rows := m.historyRows(entries, matches).rows
+	for _, row := range m.scrolled(rows, m.historyHeight(m.reservedRows())) {
+		conversation.WriteString(row)
 		conversation.WriteString("\n")
 	}
-	if !m.waitingForResponse {
-		conversation.WriteString(m.textInput.View())
-	}
+	conversation.WriteString(m.textInput.View())
 	return conversation.String()
 }
 
-func (m model) introduce(name string) tea.Cmd {
+func (s session) introduce(name string) tea.Cmd {
 	return func() tea.Msg {
-		introduceResponse, err := m.client.Introduce(context.Background(),
-			connect.NewRequest(&elizav1.IntroduceRequest{
-				Name: name,
-			}),
-		)
+		req := connect.NewRequest(&elizav1.IntroduceRequest{
+			Name: name,
+		})
+		req.Header().Set(sessionHeader, s.sessionID)
+		introduceResponse, err := s.client.Introduce(context.Background(), req)
 		if err != nil {
-			return errMsg(err)
+			return failed(name, err)
 		}
 		defer introduceResponse.Close()
 		var introductionLines []string
@@ -219,37 +762,86 @@ func (m model) introduce(name string) tea.Cmd {
 		// Receive returns false on both end-of-stream and error;
 		// surface the error if there was one.
 		if err := introduceResponse.Err(); err != nil {
-			return errMsg(err)
+			return failed(name, err)
 		}
 		return introductionMsg(introductionLines)
 	}
 }
 
-// closeConversation closes both sides of the Converse stream, if one was
-// opened, so the server handler can return.
-func (m model) closeConversation() {
-	if m.conversation != nil {
-		_ = m.conversation.CloseRequest()
-		_ = m.conversation.CloseResponse()
+// send says text to ELIZA, opening the Converse stream if need be.
+func (m model) send(text string) (model, tea.Cmd) {
+	m.sentAt = time.Now()
+	if m.mode == unaryMode {
+		return m, m.forSession(m.sayUnary(text))
+	}
+	if m.conversation == nil {
+		// Open the bidi stream once, on first use; it is reused for
+		// the rest of the conversation.
+		m.openConversation()
+	}
+	return m, m.forSession(m.sayOnStream(text))
+}
+
+// sendIntroduction introduces the user to ELIZA as name.
+func (m model) sendIntroduction(name string) (model, tea.Cmd) {
+	m.sentAt = time.Now()
+	return m, m.forSession(m.introduce(name))
+}
+
+// sendQueued sends the first queued sentence, if there is one.
+func (m model) sendQueued() (tea.Model, tea.Cmd) {
+	i := m.firstPending()
+	if i < 0 {
+		return m, nil
+	}
+	selected := -1
+	if entries := m.entries(); m.selecting && !m.background && m.selected >= 0 && m.selected < len(entries) {
+		selected = entries[m.selected].remark
+	}
+	moved := m.markSent(i)
+	m.waitingForResponse = true
+	if selected >= 0 {
+		// Keep the same message selected, wherever it's moved to.
+		m.selected = m.entryOf(m.entries(), moved(selected))
+	}
+	return m.send(m.remarks[len(m.remarks)-1].text)
+}
+
+// sayFailed marks the last sentence sent failed, since ELIZA won't be
+// replying to it, and carries on with the next.
+func (m model) sayFailed(err error) (tea.Model, tea.Cmd) {
+	slog.Error("sentence failed", errorAttrs(err)...)
+	m.waitingForResponse = false
+	m.retries = 0
+	reason := err.Error()
+	if connectErr := new(connect.Error); errors.As(err, &connectErr) {
+		reason = connectErr.Message()
+	}
+	if i := m.lastSent(); i >= 0 {
+		m.setStatus(i, statusFailed, reason)
 	}
+	// The stream is no use after an error; the next sentence opens
+	// another.
+	m.dropConversation()
+	m.notice = failure("ELIZA couldn't reply: %s.", reason)
+	return m.sendQueued()
 }
 
-func (m model) say(text string) tea.Cmd {
+// sayUnary sends text in its own Say call. Only a service that keeps
+// sessions remembers the conversation so far.
+func (s session) sayUnary(text string) tea.Cmd {
 	return func() tea.Msg {
-		if err := m.conversation.Send(
-			&elizav1.ConverseRequest{
-				Sentence: text,
-			},
-		); err != nil {
-			return errMsg(err)
-		}
-		conversationResponse, err := m.conversation.Receive()
+		req := connect.NewRequest(&elizav1.SayRequest{
+			Sentence: text,
+		})
+		req.Header().Set(sessionHeader, s.sessionID)
+		sayResponse, err := s.client.Say(context.Background(), req)
 		if err != nil {
-			return errMsg(err)
+			return sayFailed(text, err)
 		}
-		// Eliza is too fast to respond, generally.
-		// Wait a second to make things appear slow.
+		received := time.Now()
+		// Like a reply on the stream, wait to seem thoughtful.
 		time.Sleep(time.Second)
-		return sayMsg(conversationResponse.Sentence)
+		return sayMsg{sentence: sayResponse.Msg.Sentence, received: received}
 	}
 }
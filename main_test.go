@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -12,6 +13,7 @@ import (
 	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
 	"connectrpc.com/connect"
 	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/x/ansi"
 	"go.akshayshah.org/attest"
 	"go.akshayshah.org/memhttp"
 	"net/http"
@@ -164,24 +166,118 @@ func startFakeServerWithHandler(t *testing.T) (elizav1connect.ElizaServiceClient
 	return elizav1connect.NewElizaServiceClient(server.Client(), "https://example.com"), handler
 }
 
+// An inbox stands in for a running program, collecting the messages sent
+// to it from outside the event loop.
+type inbox chan tea.Msg
+
+func (in inbox) Send(msg tea.Msg) {
+	in <- msg
+}
+
+// withInbox returns m posting to an inbox, if it isn't already, and the
+// inbox.
+func withInbox(m model) (model, inbox) {
+	in, ok := m.program.(inbox)
+	if !ok {
+		in = make(inbox, 64)
+		m.program = in
+	}
+	return m, in
+}
+
+// next returns the next message posted to in.
+func (in inbox) next(t *testing.T) tea.Msg {
+	t.Helper()
+
+	select {
+	case msg := <-in:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return nil
+	}
+}
+
+// unwrap returns the message inside any session and stream messages.
+func unwrap(msg tea.Msg) tea.Msg {
+	for {
+		switch m := msg.(type) {
+		case sessionMsg:
+			msg = m.msg
+		case streamMsg:
+			msg = m.msg
+		default:
+			return msg
+		}
+	}
+}
+
 // sendMessage drives a full conversation exchange through the Update loop:
 // it types text, presses enter, executes the returned command, and feeds the
-// resulting message back into Update — the way the Bubble Tea runtime would.
+// resulting messages back into Update until ELIZA replies — the way the
+// Bubble Tea runtime would.
 func sendMessage(t *testing.T, m model, text string) model {
 	t.Helper()
 
+	m, in := withInbox(m)
 	m.textInput.SetValue(text)
 	newModel, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
 	m = newModel.(model)
 	attest.True(t, cmd != nil, attest.Sprintf("expected a command from enter"))
 
-	msg := cmd()
-	if err, ok := msg.(errMsg); ok {
-		t.Fatalf("expected sayMsg, got error: %v", err)
+	for m.waitingForResponse {
+		var msg tea.Msg
+		if cmd != nil {
+			msg, cmd = cmd(), nil
+		} else {
+			msg = in.next(t)
+		}
+		if msg == nil {
+			continue
+		}
+		switch inner := unwrap(msg).(type) {
+		case errMsg:
+			t.Fatalf("expected sayMsg, got error: %v", inner)
+		case sayFailedMsg:
+			t.Fatalf("expected sayMsg, got error: %v", inner.err)
+		}
+		newModel, cmd = m.Update(msg)
+		m = newModel.(model)
 	}
+	return m
+}
+
+// said returns the sentences sent to ELIZA, whether she replied or not.
+func said(m model) []string {
+	return remarkTexts(m, func(r remark) bool {
+		return r.kind == kindSentence && (r.status == statusSent || r.status == statusFailed)
+	})
+}
+
+// queued returns the sentences waiting to be sent.
+func queued(m model) []string {
+	return remarkTexts(m, func(r remark) bool {
+		return r.status == statusPending
+	})
+}
 
-	newModel, _ = m.Update(msg)
-	return newModel.(model)
+// replies returns ELIZA's replies since the introduction.
+func replies(m model) []string {
+	return remarkTexts(m, func(r remark) bool {
+		return r.kind == kindReply
+	})
+}
+
+// remarkTexts returns the text of each remark in m's log that keep reports
+// true for.
+func remarkTexts(m model, keep func(remark) bool) []string {
+	var texts []string
+	for _, r := range m.remarks {
+		if keep(r) {
+			texts = append(texts, r.text)
+		}
+	}
+	return texts
 }
 
 func TestConverseStreamIsReused(t *testing.T) {
@@ -191,12 +287,12 @@ func TestConverseStreamIsReused(t *testing.T) {
 	m := initialModel(client)
 	m.hasIntroduced = true
 	m.name = "User"
-	m.introductionReceived = []string{"Hello User"}
+	m.addIntroduction([]string{"Hello User"})
 
 	m = sendMessage(t, m, "hello")
 	m = sendMessage(t, m, "how are you?")
 
-	attest.Equal(t, len(m.sayResponses), 2)
+	attest.Equal(t, len(replies(m)), 2)
 	// Both messages must travel over a single Converse stream.
 	attest.Equal(t, handler.converseCalls.Load(), int32(1))
 }
@@ -208,7 +304,7 @@ func TestConverseStreamClosedOnQuit(t *testing.T) {
 	m := initialModel(client)
 	m.hasIntroduced = true
 	m.name = "User"
-	m.introductionReceived = []string{"Hello User"}
+	m.addIntroduction([]string{"Hello User"})
 
 	m = sendMessage(t, m, "hello")
 
@@ -225,27 +321,71 @@ func TestConverseStreamClosedOnQuit(t *testing.T) {
 	}
 }
 
-func TestEnterWhileWaitingForResponseIsIgnored(t *testing.T) {
+func TestEnterWhileWaitingForResponseQueues(t *testing.T) {
 	t.Parallel()
 
 	client := startFakeServer(t)
-	m := initialModel(client)
+	m, in := withInbox(initialModel(client))
 	m.hasIntroduced = true
 	m.name = "User"
-	m.introductionReceived = []string{"Hello User"}
-	m.said = []string{"first message"}
-	m.sayResponses = nil // response not yet received
-	m.waitingForResponse = true
+	m.addIntroduction([]string{"Hello User"})
+	typeAndEnter := func(text string) tea.Cmd {
+		m.textInput.SetValue(text)
+		newModel, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+		m = newModel.(model)
+		return cmd
+	}
 
-	// Pressing enter while waiting must not send another message.
-	newModel, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	first := typeAndEnter("first")
+	attest.True(t, m.waitingForResponse)
+	// Sentences typed while waiting are queued, not sent.
+	attest.Zero(t, typeAndEnter("second"))
+	attest.Zero(t, typeAndEnter("third"))
+	attest.Zero(t, typeAndEnter("/clear"))
+	attest.Equal(t, said(m), []string{"first"})
+	attest.Equal(t, queued(m), []string{"second", "third"})
+	attest.Subsequence(t, ansi.Strip(m.View().Content), "User: third (pending)")
+
+	// ELIZA's reply sends the next queued sentence.
+	attest.Zero(t, first())
+	newModel, second := m.Update(in.next(t))
 	m = newModel.(model)
-	attest.True(t, cmd == nil, attest.Sprintf("enter while waiting should be ignored"))
-	attest.Equal(t, len(m.said), 1)
+	attest.True(t, second != nil)
+	attest.Equal(t, said(m), []string{"first", "second"})
+	attest.Equal(t, queued(m), []string{"third"})
 
-	// And the view must render without panicking.
-	view := m.View()
-	attest.True(t, len(view.Content) > 0)
+	// Queued sentences can be deleted before they're sent...
+	newModel, _ = m.Update(tea.KeyPressMsg{Code: 's', Mod: tea.ModCtrl})
+	m = newModel.(model)
+	newModel, _ = m.Update(tea.KeyPressMsg{Code: 'd', Text: "d"})
+	m = newModel.(model)
+	// It stays in the log, and selected, but won't be sent.
+	attest.Equal(t, len(queued(m)), 0)
+	attest.True(t, m.selecting)
+	attest.Equal(t, m.entries()[m.selected].text, "third")
+	attest.Equal(t, m.entries()[m.selected].status, statusCancelled)
+	attest.Subsequence(t, ansi.Strip(m.View().Content), "User: third (cancelled)")
+	attest.False(t, strings.Contains(m.transcriptText(), "third"))
+
+	// ...or taken back to edit.
+	newModel, _ = m.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	m = newModel.(model)
+	attest.Zero(t, typeAndEnter("fourth"))
+	newModel, _ = m.Update(tea.KeyPressMsg{Code: 's', Mod: tea.ModCtrl})
+	m = newModel.(model)
+	newModel, _ = m.Update(tea.KeyPressMsg{Code: 'e', Text: "e"})
+	m = newModel.(model)
+	attest.Equal(t, len(queued(m)), 0)
+	attest.False(t, m.selecting)
+	attest.Equal(t, m.textInput.Value(), "fourth")
+
+	attest.Zero(t, second())
+	newModel, cmd := m.Update(in.next(t))
+	m = newModel.(model)
+	attest.Zero(t, cmd)
+	attest.False(t, m.waitingForResponse)
+	attest.Equal(t, len(replies(m)), 2)
+	m.closeConversations()
 }
 
 func TestIntroduceStreamErrorIsSurfaced(t *testing.T) {
@@ -271,8 +411,8 @@ func TestInitialModelConfiguration(t *testing.T) {
 	attest.False(t, m.hasIntroduced)
 	attest.False(t, m.waitingForResponse)
 	attest.Equal(t, m.err, nil)
-	attest.Equal(t, len(m.said), 0)
-	attest.Equal(t, len(m.sayResponses), 0)
+	attest.Equal(t, len(said(m)), 0)
+	attest.Equal(t, len(replies(m)), 0)
 	attest.Equal(t, m.textInput.CharLimit, 156)
 	attest.Equal(t, m.textInput.Width(), 50)
 }
@@ -347,13 +487,14 @@ func TestConversationFlowSimpleModel(t *testing.T) {
 	// First, introduce
 	m.hasIntroduced = true
 	m.name = "Charlie"
-	m.introductionReceived = []string{"Hello Charlie"}
+	m.addIntroduction([]string{"Hello Charlie"})
 
 	// The say method uses the bidirectional Converse RPC, which requires HTTP/2 support
 	// The test server has limitations with HTTP/2, so we skip execution here
 	// Instead, we verify the model structure is correct
-	cmd := m.say("How are you?")
+	m, cmd := m.send("How are you?")
 	attest.NotEqual(t, cmd, nil)
+	m.closeConversation()
 }
 
 func TestMessageUpdates(t *testing.T) {
@@ -370,7 +511,7 @@ func TestMessageUpdates(t *testing.T) {
 			check: func(t *testing.T, m *model) {
 				attest.True(t, m.hasIntroduced)
 				attest.False(t, m.waitingForResponse)
-				attest.Equal(t, len(m.introductionReceived), 2)
+				attest.Equal(t, len(m.introduction()), 2)
 			},
 		},
 		{
@@ -379,11 +520,11 @@ func TestMessageUpdates(t *testing.T) {
 				m.hasIntroduced = true
 				m.waitingForResponse = true
 			},
-			msg: sayMsg("I'm doing well"),
+			msg: sayMsg{sentence: "I'm doing well"},
 			check: func(t *testing.T, m *model) {
 				attest.False(t, m.waitingForResponse)
-				attest.Equal(t, len(m.sayResponses), 1)
-				attest.Equal(t, m.sayResponses[0], "I'm doing well")
+				attest.Equal(t, len(replies(*m)), 1)
+				attest.Equal(t, replies(*m)[0], "I'm doing well")
 			},
 		},
 	}
@@ -426,9 +567,10 @@ func TestConversationViewWithWaitingForResponse(t *testing.T) {
 	// Set up conversation state with multiple exchanges
 	m.hasIntroduced = true
 	m.name = "User"
-	m.introductionReceived = []string{"Hello User"}
-	m.said = []string{"How are you?", "That's good"}
-	m.sayResponses = []string{"I'm doing well", ""} // First response completed, second waiting
+	m.addIntroduction([]string{"Hello User"})
+	m.addSentence("How are you?", statusSent)
+	m.addFromEliza(kindReply, time.Now(), "I'm doing well")
+	m.addSentence("That's good", statusSent) // waiting for the response
 	m.waitingForResponse = true
 
 	view := m.View()
@@ -504,32 +646,28 @@ func TestSayCommand(t *testing.T) {
 	t.Parallel()
 
 	client := startFakeServer(t)
-	m := initialModel(client)
+	m, in := withInbox(initialModel(client))
 
 	// Set up as if we've already had introduction and opened the stream
 	m.hasIntroduced = true
 	m.name = "Charlie"
-	m.introductionReceived = []string{"Hello Charlie"}
-	m.conversation = m.client.Converse(context.Background())
+	m.addIntroduction([]string{"Hello Charlie"})
+	m.openConversation()
+	defer m.closeConversation()
 
-	// Execute the say command
-	cmd := m.say("How are you?")
+	// Sending returns nothing: the reply is posted by the receive loop.
+	cmd := m.sayOnStream("How are you?")
 	attest.NotEqual(t, cmd, nil)
-
-	// Actually execute the command and check the result
-	msg := cmd()
-
-	// Check what type of message we got
-	switch v := msg.(type) {
-	case sayMsg:
-		// Successfully received response from ELIZA
-		attest.True(t, len(v) > 0)
-	case errMsg:
-		// Stream communication error is acceptable - still exercises the code path
-		_ = v
-	default:
-		attest.False(t, true, attest.Sprintf("unexpected message type: %T", msg))
-	}
+	attest.Zero(t, cmd())
+
+	msg := in.next(t)
+	reply, ok := unwrap(msg).(sayMsg)
+	attest.True(t, ok, attest.Sprintf("expected sayMsg, got %T", unwrap(msg)), attest.Fatal())
+	attest.Equal(t, reply.sentence, `I see. You said: "How are you?". Tell me more.`)
+	attest.False(t, reply.received.IsZero())
+	stream, ok := msg.(sessionMsg).msg.(streamMsg)
+	attest.True(t, ok, attest.Fatal())
+	attest.Equal(t, stream.stream, m.conversation.id)
 }
 
 func TestSayCommandWithServerError(t *testing.T) {
@@ -537,23 +675,100 @@ func TestSayCommandWithServerError(t *testing.T) {
 
 	// Use an error-returning server
 	client := startFakeServerWithErrors(t)
-	m := initialModel(client)
+	m, in := withInbox(initialModel(client))
 
 	// Set up as if we've already had introduction and opened the stream
 	m.hasIntroduced = true
 	m.name = "User"
-	m.introductionReceived = []string{"Hello User"}
-	m.conversation = m.client.Converse(context.Background())
+	m.addIntroduction([]string{"Hello User"})
+	m.openConversation()
+	m.waitingForResponse = true
+	m.addSentence("Tell me more", statusSent)
 
-	// Execute the say command - should fail because server returns error
-	cmd := m.say("Tell me more")
+	// Execute the say command - the server's error comes from receiving
+	cmd := m.sayOnStream("Tell me more")
 	attest.NotEqual(t, cmd, nil)
+	if msg := cmd(); msg != nil {
+		_, ok := unwrap(msg).(retryMsg)
+		attest.False(t, ok)
+	}
+
+	msg := in.next(t)
+	_, ok := unwrap(msg).(streamFailedMsg)
+	attest.True(t, ok, attest.Sprintf("expected streamFailedMsg, got %T", unwrap(msg)))
+	next, _ := m.Update(msg)
+	m = next.(model)
+
+	// The sentence failed, but the conversation can carry on.
+	attest.Ok(t, m.err)
+	attest.False(t, m.waitingForResponse)
+	attest.Equal(t, m.remarks[len(m.remarks)-1].status, statusFailed)
+	attest.Subsequence(t, m.remarks[len(m.remarks)-1].err, "converse error")
+	attest.Zero(t, m.conversation)
+	attest.Subsequence(t, m.notice.text, "ELIZA couldn't reply")
+	attest.Subsequence(t, ansi.Strip(m.View().Content), "User: Tell me more (failed)")
+}
+
+func TestStreamMsgFromClosedStreamIsIgnored(t *testing.T) {
+	t.Parallel()
+
+	m, in := withInbox(initialModel(startFakeServer(t)))
+	m.hasIntroduced = true
+	m.name = "User"
+	m.addIntroduction([]string{"Hello User"})
+	m.openConversation()
+	first := m.conversation.id
+	attest.Zero(t, m.sayOnStream("hello")())
+	reply := in.next(t)
+
+	// The reply arrives after /reconnect has replaced the stream.
+	m.dropConversation()
+	m.openConversation()
+	defer m.closeConversation()
+	attest.NotEqual(t, m.conversation.id, first)
+	next, _ := m.Update(reply)
+	m = next.(model)
+	attest.Equal(t, len(replies(m)), 0)
+}
+
+func TestRepliesInAnyOrder(t *testing.T) {
+	t.Parallel()
 
-	// Execute the command
-	msg := cmd()
+	m, _ := withInbox(initialModel(startFakeServer(t)))
+	m.hasIntroduced = true
+	m.name = "User"
+	m.addIntroduction([]string{"Hello User"})
+	m.openConversation()
+	defer m.closeConversation()
+	id := m.conversation.id
+	update := func(msg tea.Msg) {
+		next, _ := m.Update(sessionMsg{id: m.id, msg: streamMsg{stream: id, msg: msg}})
+		m = next.(model)
+	}
 
-	// Should get an error since server fails
-	errMsg, ok := msg.(errMsg)
-	attest.True(t, ok, attest.Sprintf("expected errMsg, got %T", msg))
-	attest.True(t, errMsg != nil)
+	// ELIZA speaks unprompted, then twice to one sentence.
+	update(sayMsg{sentence: "Are you there?"})
+	m.addSentence("Yes", statusSent)
+	m.waitingForResponse = true
+	update(sayMsg{sentence: "Good."})
+	update(sayMsg{sentence: "Let's begin."})
+	attest.False(t, m.waitingForResponse)
+	attest.Equal(t, m.transcriptText(), "Eliza: Hello User\n"+
+		"Eliza: Are you there?\n"+
+		"User: Yes\n"+
+		"Eliza: Good.\n"+
+		"Eliza: Let's begin.\n")
+	for i := 1; i < len(m.remarks); i++ {
+		attest.False(t, m.remarks[i].at.Before(m.remarks[i-1].at))
+	}
+
+	// Ending the stream without replying stops the wait.
+	m.addSentence("Hello?", statusSent)
+	m.waitingForResponse = true
+	update(conversationEndedMsg{})
+	attest.False(t, m.waitingForResponse)
+	attest.Zero(t, m.conversation)
+	attest.Subsequence(t, m.notice.text, "ELIZA ended the conversation")
+	attest.Equal(t, m.remarks[len(m.remarks)-1].status, statusFailed)
+	attest.False(t, strings.Contains(m.transcriptText(), "Hello?"))
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os/exec"
+	"runtime"
+	"strings"
+	"unicode"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// A notifier tells the user about ELIZA's replies while the terminal is
+// unfocused, since on a slow service they can take a while.
+type notifier struct {
+	// bell rings the terminal bell.
+	bell bool
+	// desktop asks the terminal for a desktop notification, with OSC 9
+	// (iTerm2, WezTerm, Windows Terminal) and OSC 777 (rxvt, foot,
+	// Ghostty). Terminals ignore the one they don't understand.
+	desktop bool
+	// command is run by the shell with the reply on its stdin, if set.
+	command string
+}
+
+// notify returns a command telling the user that ELIZA said text, or nil if
+// there's nothing to do.
+func (n notifier) notify(text string) tea.Cmd {
+	var cmds []tea.Cmd
+	var seq strings.Builder
+	if n.bell {
+		seq.WriteByte(ansi.BEL)
+	}
+	if n.desktop {
+		body := printable(text)
+		seq.WriteString(ansi.Notify("ELIZA: " + body))
+		seq.WriteString("\x1b]777;notify;ELIZA;" + body + "\x07")
+	}
+	if seq.Len() > 0 {
+		cmds = append(cmds, tea.Raw(seq.String()))
+	}
+	if n.command != "" {
+		command := n.command
+		cmds = append(cmds, func() tea.Msg {
+			if err := runNotifyCommand(command, text); err != nil {
+				slog.Warn("running the notify command", errorAttrs(err)...)
+			}
+			return nil
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+// printable returns text without control characters, which could end an
+// escape sequence early.
+func printable(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, text)
+}
+
+// runNotifyCommand runs command with the shell, writing text and a newline
+// to its stdin. Its output would garble the TUI, so it's only logged.
+func runNotifyCommand(command, text string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdin = strings.NewReader(text + "\n")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	if output.Len() > 0 {
+		slog.Debug("notify command output", slog.String("command", command), slog.String("output", output.String()))
+	}
+	return err
+}
+
+// updateFocus tracks whether the terminal has focus, as reported by the
+// terminal.
+func (m model) updateFocus(focused bool) (tea.Model, tea.Cmd) {
+	m.unfocused = !focused
+	return m, nil
+}
+
+// notifyReply returns a command telling the user that ELIZA said text, if
+// the terminal is unfocused.
+func (m model) notifyReply(text string) tea.Cmd {
+	if !m.unfocused {
+		return nil
+	}
+	return m.notifier.notify(text)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"go.akshayshah.org/attest"
+)
+
+// runNotify runs the commands cmd batches, returning what they write to the
+// terminal.
+func runNotify(cmd tea.Cmd) string {
+	if cmd == nil {
+		return ""
+	}
+	var raw strings.Builder
+	switch msg := cmd().(type) {
+	case tea.BatchMsg:
+		for _, c := range msg {
+			raw.WriteString(runNotify(c))
+		}
+	case tea.RawMsg:
+		raw.WriteString(fmt.Sprint(msg.Msg))
+	}
+	return raw.String()
+}
+
+func TestReplyNotifiesWhileUnfocused(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel(startFakeServer(t))
+	m.hasIntroduced = true
+	m.name = "Alice"
+	m.notifier = notifier{bell: true, desktop: true}
+	reply := func(text string) string {
+		next, cmd := m.Update(sessionMsg{id: m.id, msg: sayMsg{sentence: text}})
+		m = next.(model)
+		return runNotify(cmd)
+	}
+
+	attest.True(t, m.View().ReportFocus)
+	attest.Equal(t, reply("Hello."), "")
+
+	next, _ := m.Update(tea.BlurMsg{})
+	m = next.(model)
+	attest.Equal(t, reply("Why\x1b do you say that?"), "\a"+
+		"\x1b]9;ELIZA: Why  do you say that?\a"+
+		"\x1b]777;notify;ELIZA;Why  do you say that?\a")
+
+	next, _ = m.Update(tea.FocusMsg{})
+	m = next.(model)
+	attest.Equal(t, reply("Go on."), "")
+	attest.Equal(t, len(replies(m)), 3)
+}
+
+func TestNotifyCommand(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("the command is written for sh")
+	}
+
+	path := filepath.Join(t.TempDir(), "reply.txt")
+	n := notifier{command: "cat > '" + path + "'"}
+	attest.Equal(t, runNotify(n.notify("I see.")), "")
+	got, err := os.ReadFile(path)
+	attest.Ok(t, err)
+	attest.Equal(t, string(got), "I see.\n")
+
+	// A failing command is only logged.
+	n.command = "exit 1"
+	attest.Equal(t, runNotify(n.notify("I see.")), "")
+	attest.Zero(t, notifier{}.notify("I see."))
+}
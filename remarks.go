@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// A remarkKind is what sort of line of the conversation a remark is.
+type remarkKind int
+
+const (
+	// kindSentence is a sentence said to ELIZA.
+	kindSentence remarkKind = iota
+	// kindReply is said by ELIZA, in reply or unprompted.
+	kindReply
+	// kindIntroduction is a line of ELIZA's introduction.
+	kindIntroduction
+	// kindRename separates the conversation before a /rename from the
+	// one after.
+	kindRename
+)
+
+func (k remarkKind) String() string {
+	switch k {
+	case kindSentence:
+		return "sentence"
+	case kindReply:
+		return "reply"
+	case kindIntroduction:
+		return "introduction"
+	case kindRename:
+		return "rename"
+	}
+	return fmt.Sprintf("remarkKind(%d)", int(k))
+}
+
+// A remarkStatus is how far a sentence got. ELIZA's lines, and separators,
+// are always sent.
+type remarkStatus string
+
+const (
+	// statusPending is a sentence queued while waiting for ELIZA.
+	statusPending remarkStatus = "pending"
+	statusSent    remarkStatus = "sent"
+	// statusFailed is a sentence ELIZA never replied to, because the
+	// request failed.
+	statusFailed remarkStatus = "failed"
+	// statusCancelled is a sentence deleted from the queue.
+	statusCancelled remarkStatus = "cancelled"
+)
+
+// A remark is a line of a session's conversation log: a sentence said to
+// ELIZA, one of her replies or introductions, or a separator. Remarks are
+// kept in the order they happened, since ELIZA needn't reply exactly once
+// to each sentence, or only when spoken to. The history is rendered from
+// the log, and the transcripts exported from it.
+type remark struct {
+	kind remarkKind
+	// at is when the remark was said, or for a sentence, typed and then
+	// sent.
+	at      time.Time
+	speaker string // empty for a separator
+	text    string
+	status  remarkStatus
+	// latency is how long ELIZA took to say a line, from sending the
+	// request it answers, or zero if it wasn't in answer to one.
+	latency time.Duration
+	backend string // the URL of the ELIZA service
+	err     string // why a sentence failed
+	// announced is set once r has been printed in accessible mode.
+	announced bool
+}
+
+// fromEliza reports whether ELIZA said r.
+func (r remark) fromEliza() bool {
+	return r.kind == kindReply || r.kind == kindIntroduction
+}
+
+// addSentence adds a sentence said by the user to the log, returning its
+// index.
+func (s *session) addSentence(text string, status remarkStatus) int {
+	s.remarks = append(s.remarks, remark{
+		kind:    kindSentence,
+		at:      time.Now(),
+		speaker: s.name,
+		text:    text,
+		status:  status,
+		backend: s.url,
+	})
+	return len(s.remarks) - 1
+}
+
+// addFromEliza adds lines that ELIZA said, received at the given time, to
+// the log.
+func (s *session) addFromEliza(kind remarkKind, received time.Time, lines ...string) {
+	var latency time.Duration
+	if s.waitingForResponse && !s.sentAt.IsZero() {
+		latency = max(0, received.Sub(s.sentAt))
+	}
+	for _, line := range lines {
+		s.remarks = append(s.remarks, remark{
+			kind:    kind,
+			at:      received,
+			speaker: "Eliza",
+			text:    line,
+			status:  statusSent,
+			latency: latency,
+			backend: s.url,
+		})
+	}
+}
+
+// addIntroduction adds ELIZA's introduction to the log.
+func (s *session) addIntroduction(lines []string) {
+	s.addFromEliza(kindIntroduction, time.Now(), lines...)
+}
+
+// introduction returns the lines of ELIZA's introduction since the last
+// /rename.
+func (s session) introduction() []string {
+	var lines []string
+	for _, r := range s.remarks {
+		switch r.kind {
+		case kindRename:
+			lines = nil
+		case kindIntroduction:
+			lines = append(lines, r.text)
+		}
+	}
+	return lines
+}
+
+// lastSent returns the index of the last sentence sent to ELIZA, or -1 if
+// there isn't one.
+func (s session) lastSent() int {
+	for i, r := range slices.Backward(s.remarks) {
+		if r.kind == kindSentence && r.status == statusSent {
+			return i
+		}
+	}
+	return -1
+}
+
+// firstPending returns the index of the first queued sentence, or -1 if
+// there isn't one.
+func (s session) firstPending() int {
+	return slices.IndexFunc(s.remarks, func(r remark) bool {
+		return r.status == statusPending
+	})
+}
+
+// markSent marks the queued sentence at index i sent, now, moving it to the
+// end of the log. It returns where each remark has moved to.
+func (s *session) markSent(i int) func(int) int {
+	r := s.remarks[i]
+	r.at = time.Now()
+	r.status = statusSent
+	s.remarks = append(slices.Delete(slices.Clone(s.remarks), i, i+1), r)
+	return func(j int) int {
+		switch {
+		case j == i:
+			return len(s.remarks) - 1
+		case j > i:
+			return j - 1
+		}
+		return j
+	}
+}
+
+// setStatus sets the status of the sentence at index i, and why it failed.
+func (s *session) setStatus(i int, status remarkStatus, reason string) {
+	s.remarks = slices.Clone(s.remarks)
+	s.remarks[i].status = status
+	s.remarks[i].err = reason
+}
+
+// A jsonRemark is a remark as exported by /export json.
+type jsonRemark struct {
+	Kind      string  `json:"kind"`
+	Time      string  `json:"time"`
+	Speaker   string  `json:"speaker,omitempty"`
+	Text      string  `json:"text"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latencyMs,omitempty"`
+	Backend   string  `json:"backend,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// transcriptJSON returns the whole conversation log as JSON, including the
+// sentences that were never sent.
+func (s session) transcriptJSON() (string, error) {
+	remarks := make([]jsonRemark, 0, len(s.remarks))
+	for _, r := range s.remarks {
+		remarks = append(remarks, jsonRemark{
+			Kind:      r.kind.String(),
+			Time:      r.at.Format(time.RFC3339Nano),
+			Speaker:   r.speaker,
+			Text:      r.text,
+			Status:    string(r.status),
+			LatencyMS: float64(r.latency) / float64(time.Millisecond),
+			Backend:   r.backend,
+			Error:     r.err,
+		})
+	}
+	data, err := json.MarshalIndent(remarks, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+const (
+	// maxRetries is how many times in a row a request is resent before
+	// giving up.
+	maxRetries = 5
+	// maxRetryDelay caps the wait before a retry, whatever the server
+	// says.
+	maxRetryDelay = time.Minute
+)
+
+// A pendingRetry is a request that the server turned away for now, to be
+// resent when the wait is over.
+type pendingRetry struct {
+	at     time.Time
+	text   string // the name or sentence to resend
+	reason string
+}
+
+// retryMsg reports that sending text failed with err, but can be retried
+// after delay.
+type retryMsg struct {
+	text  string
+	delay time.Duration
+	err   error
+}
+
+// retryTickMsg updates the countdown to a retry, and resends the request
+// when it's over.
+type retryTickMsg struct{}
+
+// failed returns the message for sending text failing with err: a retry
+// if the server is overloaded or unavailable, and an error otherwise.
+func failed(text string, err error) tea.Msg {
+	delay, ok := retryDelay(err, time.Now())
+	if !ok {
+		return errMsg(err)
+	}
+	return retryMsg{text: text, delay: delay, err: err}
+}
+
+// sayFailed returns the message for sending sentence text failing with err:
+// a retry if the server is overloaded or unavailable, and otherwise a
+// sayFailedMsg.
+func sayFailed(text string, err error) tea.Msg {
+	if msg, ok := failed(text, err).(retryMsg); ok {
+		return msg
+	}
+	return sayFailedMsg{err: err}
+}
+
+// retryDelay reports whether a request that failed with err should be
+// retried and, if the server said, how long to wait first. The wait comes
+// from a RetryInfo error detail or a Retry-After header, relative to now.
+func retryDelay(err error, now time.Time) (time.Duration, bool) {
+	switch connect.CodeOf(err) {
+	case connect.CodeResourceExhausted, connect.CodeUnavailable:
+	default:
+		return 0, false
+	}
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return 0, true
+	}
+	for _, detail := range connectErr.Details() {
+		value, err := detail.Value()
+		if err != nil {
+			continue
+		}
+		if info, ok := value.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+	if delay, ok := parseRetryAfter(connectErr.Meta().Get("Retry-After"), now); ok {
+		return delay, true
+	}
+	return 0, true
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(0, at.Sub(now)), true
+	}
+	return 0, false
+}
+
+// backoff returns how long to wait before the nth retry in a row, if the
+// server didn't say.
+func backoff(n int) time.Duration {
+	return min(maxRetryDelay, time.Second<<min(n-1, 6))
+}
+
+// scheduleRetry starts the countdown to resending a request that the server
+// turned away.
+func (m model) scheduleRetry(msg retryMsg) (tea.Model, tea.Cmd) {
+	m.retries++
+	if m.retries > maxRetries {
+		err := fmt.Errorf("gave up after %d retries: %w", maxRetries, msg.err)
+		if len(m.introduction()) == 0 {
+			// There's no conversation to carry on with.
+			return m.Update(errMsg(err))
+		}
+		return m.sayFailed(err)
+	}
+	delay := msg.delay
+	if delay <= 0 {
+		delay = backoff(m.retries)
+	}
+	delay = min(delay, maxRetryDelay)
+	slog.Warn("request turned away, retrying",
+		append(errorAttrs(msg.err), slog.Duration("delay", delay), slog.Int("retry", m.retries))...)
+	// The stream is done for; the retry opens a new one.
+	m.dropConversation()
+	m.retry = &pendingRetry{
+		at:     time.Now().Add(delay),
+		text:   msg.text,
+		reason: connect.CodeOf(msg.err).String(),
+	}
+	if connectErr := new(connect.Error); errors.As(msg.err, &connectErr) {
+		m.retry.reason = connectErr.Message()
+	}
+	return m, m.forSession(m.retryTick())
+}
+
+// retryTick waits until the countdown to the pending retry should next be
+// updated.
+func (s session) retryTick() tea.Cmd {
+	// Tick as each whole second remaining passes.
+	remaining := time.Until(s.retry.at)
+	wait := remaining % time.Second
+	if wait <= 0 {
+		wait = min(time.Second, max(0, remaining))
+	}
+	return tea.Tick(wait, func(time.Time) tea.Msg {
+		return retryTickMsg{}
+	})
+}
+
+// updateRetry counts down to the pending retry, and resends the request
+// when it's time.
+func (m model) updateRetry() (tea.Model, tea.Cmd) {
+	if m.retry == nil {
+		return m, nil
+	}
+	if time.Now().Before(m.retry.at) {
+		return m, m.forSession(m.retryTick())
+	}
+	text := m.retry.text
+	m.retry = nil
+	if len(m.introduction()) == 0 {
+		// The introduction, or a /rename's, was turned away.
+		return m.sendIntroduction(text)
+	}
+	return m.send(text)
+}
+
+// retryView renders the countdown to the pending retry, if there is one.
+func (s session) retryView() string {
+	if s.retry == nil {
+		return ""
+	}
+	return "\n\n" + faintStyle.Render(s.retryStatus())
+}
+
+// retryStatus describes the pending retry.
+func (s session) retryStatus() string {
+	seconds := int(math.Ceil(time.Until(s.retry.at).Seconds()))
+	return fmt.Sprintf("ELIZA is busy (%s). Retrying in %ds…", s.retry.reason, max(0, seconds))
+}
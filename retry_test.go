@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
+	tea "charm.land/bubbletea/v2"
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp"
+)
+
+func TestRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	withHeader := func(code connect.Code, value string) error {
+		err := connect.NewError(code, errors.New("busy"))
+		err.Meta().Set("Retry-After", value)
+		return err
+	}
+	tests := []struct {
+		name  string
+		err   error
+		delay time.Duration
+		ok    bool
+	}{
+		{"retry info", resourceExhausted("busy", 1500*time.Millisecond), 1500 * time.Millisecond, true},
+		{"seconds", withHeader(connect.CodeUnavailable, "3"), 3 * time.Second, true},
+		{"date", withHeader(connect.CodeResourceExhausted, now.Add(time.Minute).Format(http.TimeFormat)), time.Minute, true},
+		{"no hint", connect.NewError(connect.CodeUnavailable, errors.New("down")), 0, true},
+		{"not retryable", withHeader(connect.CodeInternal, "3"), 0, false},
+		{"not a connect error", errors.New("boom"), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, ok := retryDelay(tt.err, now)
+			attest.Equal(t, ok, tt.ok)
+			attest.Equal(t, delay, tt.delay)
+		})
+	}
+}
+
+// busyHandler turns away the first calls to Introduce and Converse.
+type busyHandler struct {
+	*fakeElizaServiceHandler
+
+	busy atomic.Int32 // how many more calls to turn away
+}
+
+func (h *busyHandler) Introduce(
+	ctx context.Context,
+	req *connect.Request[elizav1.IntroduceRequest],
+	stream *connect.ServerStream[elizav1.IntroduceResponse],
+) error {
+	if h.busy.Add(-1) >= 0 {
+		return resourceExhausted("too many requests", 10*time.Millisecond)
+	}
+	return h.fakeElizaServiceHandler.Introduce(ctx, req, stream)
+}
+
+func (h *busyHandler) Converse(
+	ctx context.Context,
+	stream *connect.BidiStream[elizav1.ConverseRequest, elizav1.ConverseResponse],
+) error {
+	if h.busy.Add(-1) >= 0 {
+		return resourceExhausted("too many requests", 10*time.Millisecond)
+	}
+	return h.fakeElizaServiceHandler.Converse(ctx, stream)
+}
+
+// startBusyServer creates an in-memory ELIZA service that turns away as
+// many calls as its handler's busy count.
+func startBusyServer(t *testing.T) (elizav1connect.ElizaServiceClient, *busyHandler) {
+	t.Helper()
+
+	handler := &busyHandler{fakeElizaServiceHandler: &fakeElizaServiceHandler{}}
+	mux := http.NewServeMux()
+	mux.Handle(elizav1connect.NewElizaServiceHandler(handler))
+	server, err := memhttp.New(mux)
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() {
+		attest.Ok(t, server.Close())
+	})
+	return elizav1connect.NewElizaServiceClient(server.Client(), server.URL()), handler
+}
+
+// settle runs cmd and the commands that follow from it, and applies the
+// messages posted to the inbox, until there's nothing left to wait for. It
+// reports whether a retry countdown was shown along the way.
+func settle(t *testing.T, m model, cmd tea.Cmd) (model, bool) {
+	t.Helper()
+
+	m, in := withInbox(m)
+	counted := false
+	for m.err == nil && (cmd != nil || m.waitingForResponse) {
+		var msg tea.Msg
+		if cmd != nil {
+			msg, cmd = cmd(), nil
+		} else {
+			msg = in.next(t)
+		}
+		next, nextCmd := m.Update(msg)
+		m, cmd = next.(model), nextCmd
+		if m.retry != nil {
+			attest.Subsequence(t, m.View().Content, "ELIZA is busy (too many requests). Retrying in 1s")
+			counted = true
+		}
+	}
+	return m, counted
+}
+
+func TestBusyServerIsRetried(t *testing.T) {
+	t.Parallel()
+
+	client, handler := startBusyServer(t)
+	handler.busy.Store(1)
+	m := initialModel(client)
+
+	m.textInput.SetValue("Alice")
+	next, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	m, counted := settle(t, next.(model), cmd)
+	attest.True(t, counted)
+	attest.True(t, m.hasIntroduced)
+	attest.Equal(t, m.introduction()[0], "Hello Alice, I'm ELIZA.")
+
+	handler.busy.Store(1)
+	m.textInput.SetValue("hello")
+	next, cmd = m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	m, counted = settle(t, next.(model), cmd)
+	attest.True(t, counted)
+	attest.Equal(t, said(m), []string{"hello"})
+	attest.Equal(t, replies(m), []string{`I see. You said: "hello". Tell me more.`})
+	attest.Equal(t, m.retries, 0)
+
+	// Giving up on a sentence fails it, but not the conversation.
+	m.dropConversation()
+	handler.busy.Store(maxRetries + 1)
+	m.textInput.SetValue("anyone?")
+	next, cmd = m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	m, _ = settle(t, next.(model), cmd)
+	attest.Ok(t, m.err)
+	attest.Equal(t, m.remarks[len(m.remarks)-1].status, statusFailed)
+	attest.Subsequence(t, m.remarks[len(m.remarks)-1].err, "too many requests")
+	attest.Equal(t, said(m), []string{"hello", "anyone?"})
+	m.closeConversations()
+
+	// The server turning the client away too often is an error.
+	handler.busy.Store(maxRetries + 1)
+	m = initialModel(client)
+	m.textInput.SetValue("Bob")
+	next, cmd = m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	m, _ = settle(t, next.(model), cmd)
+	attest.Equal(t, connect.CodeOf(m.err), connect.CodeResourceExhausted)
+}
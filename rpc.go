@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// rpcCall makes one call to the ELIZA service, writing the responses to out
+// and, if it's not nil, the response headers and trailers to meta.
+type rpcCall func(ctx context.Context, client elizav1connect.ElizaServiceClient, args []string, out, meta io.Writer) error
+
+func runRPC(args []string) (err error) {
+	const usage = "usage: eliza rpc introduce|say|converse [flags]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	var (
+		opts    commonOptions
+		baseURL string
+		name    string
+		verbose bool
+		call    rpcCall
+	)
+	fs := flag.NewFlagSet("eliza rpc "+args[0], flag.ExitOnError)
+	opts.register(fs)
+	fs.StringVar(&baseURL, "url", defaultURL, "base `URL` of the ELIZA service")
+	fs.BoolVar(&verbose, "v", false, "print response headers and trailers to stderr")
+	switch args[0] {
+	case "introduce":
+		fs.StringVar(&name, "name", "", "`name` to introduce yourself with")
+		call = func(ctx context.Context, client elizav1connect.ElizaServiceClient, _ []string, out, meta io.Writer) error {
+			return rpcIntroduce(ctx, client, name, out, meta)
+		}
+	case "say":
+		call = func(ctx context.Context, client elizav1connect.ElizaServiceClient, args []string, out, meta io.Writer) error {
+			if len(args) == 0 {
+				return errors.New("usage: eliza rpc say [flags] sentence")
+			}
+			return rpcSay(ctx, client, strings.Join(args, " "), out, meta)
+		}
+	case "converse":
+		call = func(ctx context.Context, client elizav1connect.ElizaServiceClient, _ []string, out, meta io.Writer) error {
+			return rpcConverse(ctx, client, os.Stdin, out, meta)
+		}
+	default:
+		return fmt.Errorf("unknown RPC %q: %s", args[0], usage)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	interceptors, cleanup, err := opts.setup()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, cleanup())
+	}()
+	client, closeClient, err := newRemoteClient(baseURL, connect.WithInterceptors(interceptors...))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, closeClient())
+	}()
+
+	var meta io.Writer
+	if verbose {
+		meta = os.Stderr
+	}
+	return call(context.Background(), client, fs.Args(), os.Stdout, meta)
+}
+
+func rpcIntroduce(ctx context.Context, client elizav1connect.ElizaServiceClient, name string, out, meta io.Writer) error {
+	stream, err := client.Introduce(ctx, connect.NewRequest(&elizav1.IntroduceRequest{Name: name}))
+	if err != nil {
+		printErrorMetadata(meta, err)
+		return err
+	}
+	defer stream.Close()
+	printedHeaders := false
+	for stream.Receive() {
+		if !printedHeaders {
+			printMetadata(meta, "Response headers", stream.ResponseHeader())
+			printedHeaders = true
+		}
+		if err := printMessage(out, stream.Msg()); err != nil {
+			return err
+		}
+	}
+	if !printedHeaders {
+		printMetadata(meta, "Response headers", stream.ResponseHeader())
+	}
+	printMetadata(meta, "Response trailers", stream.ResponseTrailer())
+	return stream.Err()
+}
+
+func rpcSay(ctx context.Context, client elizav1connect.ElizaServiceClient, sentence string, out, meta io.Writer) error {
+	res, err := client.Say(ctx, connect.NewRequest(&elizav1.SayRequest{Sentence: sentence}))
+	if err != nil {
+		printErrorMetadata(meta, err)
+		return err
+	}
+	printMetadata(meta, "Response headers", res.Header())
+	if err := printMessage(out, res.Msg); err != nil {
+		return err
+	}
+	printMetadata(meta, "Response trailers", res.Trailer())
+	return nil
+}
+
+// rpcConverse sends each line of in, a ConverseRequest in JSON, over one
+// Converse stream, and prints the responses as they arrive. Blank lines are
+// skipped.
+func rpcConverse(ctx context.Context, client elizav1connect.ElizaServiceClient, in io.Reader, out, meta io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream := client.Converse(ctx)
+	var (
+		scanner  = bufio.NewScanner(in)
+		sent     bool
+		sendErr  error
+		received = make(chan error, 1)
+	)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		req := &elizav1.ConverseRequest{}
+		if err := protojson.Unmarshal(scanner.Bytes(), req); err != nil {
+			sendErr = fmt.Errorf("line %d: %w", line, err)
+			break
+		}
+		err := stream.Send(req)
+		if !sent {
+			// Nothing can be received until the first Send opens
+			// the stream.
+			sent = true
+			go func() {
+				received <- receiveConverse(stream, out, meta)
+			}()
+		}
+		if err != nil {
+			// On EOF, the real error comes from receiving.
+			if !errors.Is(err, io.EOF) {
+				sendErr = err
+			}
+			break
+		}
+	}
+	sendErr = errors.Join(sendErr, scanner.Err())
+	if !sent {
+		return errors.Join(sendErr, stream.CloseRequest(), stream.CloseResponse())
+	}
+	if sendErr != nil {
+		// Don't wait for the rest of the responses.
+		cancel()
+	}
+	closeErr := stream.CloseRequest()
+	return errors.Join(sendErr, <-received, closeErr)
+}
+
+// receiveConverse prints the responses on stream until it ends.
+func receiveConverse(stream *connect.BidiStreamForClient[elizav1.ConverseRequest, elizav1.ConverseResponse], out, meta io.Writer) (err error) {
+	defer func() {
+		err = errors.Join(err, stream.CloseResponse())
+	}()
+	for first := true; ; first = false {
+		res, err := stream.Receive()
+		if first {
+			if err != nil && !errors.Is(err, io.EOF) {
+				printErrorMetadata(meta, err)
+				return err
+			}
+			printMetadata(meta, "Response headers", stream.ResponseHeader())
+		}
+		if errors.Is(err, io.EOF) {
+			printMetadata(meta, "Response trailers", stream.ResponseTrailer())
+			return nil
+		}
+		if err != nil {
+			printMetadata(meta, "Response trailers", stream.ResponseTrailer())
+			return err
+		}
+		if err := printMessage(out, res); err != nil {
+			return err
+		}
+	}
+}
+
+// printMessage writes msg to w as a line of JSON.
+func printMessage(w io.Writer, msg proto.Message) error {
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// printMetadata writes headers or trailers to w under title, one per line,
+// unless w is nil.
+func printMetadata(w io.Writer, title string, md http.Header) {
+	if w == nil {
+		return
+	}
+	fmt.Fprintf(w, "%s:\n", title)
+	for _, k := range slices.Sorted(maps.Keys(md)) {
+		for _, v := range md[k] {
+			fmt.Fprintf(w, "  %s: %s\n", strings.ToLower(k), v)
+		}
+	}
+}
+
+// printErrorMetadata writes the metadata carried by a failed call's error.
+func printErrorMetadata(w io.Writer, err error) {
+	if connectErr := new(connect.Error); errors.As(err, &connectErr) {
+		printMetadata(w, "Error metadata", connectErr.Meta())
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp"
+)
+
+// sentences decodes the sentence from each line of JSON that an RPC
+// printed.
+func sentences(t *testing.T, out string) []string {
+	t.Helper()
+	var sentences []string
+	for line := range strings.Lines(out) {
+		var msg struct{ Sentence string }
+		attest.Ok(t, json.Unmarshal([]byte(line), &msg), attest.Fatal())
+		sentences = append(sentences, msg.Sentence)
+	}
+	return sentences
+}
+
+func TestRPCIntroduce(t *testing.T) {
+	t.Parallel()
+
+	var out, meta strings.Builder
+	err := rpcIntroduce(context.Background(), startLocalServer(t), "Charlie", &out, &meta)
+	attest.Ok(t, err)
+	attest.Equal(t, sentences(t, out.String()), introduction("Charlie"))
+	attest.Subsequence(t, meta.String(), "Response headers:\n")
+	attest.Subsequence(t, meta.String(), "  content-type: application/connect+proto\n")
+	attest.Subsequence(t, meta.String(), "Response trailers:\n")
+}
+
+// refusingInterceptor fails every stream a client opens, before it's sent,
+// with an error carrying metadata.
+type refusingInterceptor struct{}
+
+func (refusingInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return next
+}
+
+func (refusingInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		return refusingClientConn{next(ctx, spec)}
+	}
+}
+
+func (refusingInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+type refusingClientConn struct {
+	connect.StreamingClientConn
+}
+
+func (refusingClientConn) Send(any) error {
+	err := connect.NewError(connect.CodeUnavailable, errors.New("refused"))
+	err.Meta().Set("Retry-After", "1")
+	return err
+}
+
+func TestRPCIntroduceFailsBeforeStreaming(t *testing.T) {
+	t.Parallel()
+
+	server, err := memhttp.New(newServeMux(nil))
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() {
+		attest.Ok(t, server.Close())
+	})
+	client := elizav1connect.NewElizaServiceClient(server.Client(), server.URL(), connect.WithInterceptors(refusingInterceptor{}))
+
+	var out, meta strings.Builder
+	err = rpcIntroduce(context.Background(), client, "Charlie", &out, &meta)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+	attest.Equal(t, out.String(), "")
+	attest.Subsequence(t, meta.String(), "Error metadata:\n")
+	attest.Subsequence(t, meta.String(), "  retry-after: 1\n")
+}
+
+func TestRPCSay(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	err := rpcSay(context.Background(), startFakeServer(t), "hello", &out, nil)
+	attest.Ok(t, err)
+	attest.Equal(t, sentences(t, out.String()), []string{`I see. You said: "hello". Tell me more.`})
+
+	err = rpcSay(context.Background(), startFakeServerWithErrors(t), "hello", &out, nil)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnknown)
+}
+
+func TestRPCConverse(t *testing.T) {
+	t.Parallel()
+
+	var out, meta strings.Builder
+	in := strings.NewReader(`{"sentence": "My mother hates me"}` + "\n\n" + `{"sentence": "whatever"}` + "\n")
+	err := rpcConverse(context.Background(), startLocalServer(t), in, &out, &meta)
+	attest.Ok(t, err)
+	// The doctor remembers the first sentence, so both went over one
+	// stream.
+	attest.Equal(t, sentences(t, out.String()), []string{
+		"Tell me more about your family.",
+		"Let's discuss further why your mother hates you.",
+	})
+	attest.Subsequence(t, meta.String(), "Response trailers:\n")
+
+	out.Reset()
+	err = rpcConverse(context.Background(), startLocalServer(t), strings.NewReader("not json\n"), &out, nil)
+	attest.Error(t, err)
+	attest.Subsequence(t, err.Error(), "line 1")
+	attest.Equal(t, out.String(), "")
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"charm.land/bubbles/v2/key"
+	"charm.land/bubbles/v2/textinput"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+)
+
+// searchState is a search of the active tab's conversation.
+type searchState struct {
+	active bool
+	// editing is set while the pattern is being typed. Otherwise, the
+	// matches can be browsed.
+	editing       bool
+	input         textinput.Model
+	regex         bool
+	caseSensitive bool
+	// current is the index of the active match. It's clamped to the
+	// matches, so -1 is the last one.
+	current int
+}
+
+// A match is a range of an entry's text that matches the search.
+type match struct {
+	entry      int
+	start, end int
+}
+
+var (
+	matchStyle        = lipgloss.NewStyle().Reverse(true)
+	currentMatchStyle = lipgloss.NewStyle().Background(lipgloss.Yellow).Foreground(lipgloss.Black)
+)
+
+// openSearch starts a search for pattern. With no pattern, it's typed in the
+// search bar.
+func (m model) openSearch(pattern string) model {
+	input := textinput.New()
+	input.Prompt = "Search: "
+	input.SetWidth(40)
+	input.SetValue(pattern)
+	m.search = searchState{
+		active:        true,
+		editing:       pattern == "",
+		input:         input,
+		regex:         m.search.regex,
+		caseSensitive: m.search.caseSensitive,
+		current:       -1,
+	}
+	m.selecting = false
+	m.textInput.Blur()
+	if m.search.editing {
+		m.search.input.Focus()
+	}
+	return m.showMatch()
+}
+
+func (m model) closeSearch() model {
+	m.search.active = false
+	m.textInput.Focus()
+	return m
+}
+
+// updateSearch handles a key press while searching. It reports whether the
+// key was for the search; if not, it's handled as usual.
+func (m model) updateSearch(msg tea.KeyPressMsg) (model, tea.Cmd, bool) {
+	var cmd tea.Cmd
+	switch {
+	case msg.String() == "esc":
+		return m.closeSearch(), nil, true
+	case key.Matches(msg, m.keys.quit):
+		return m, nil, false
+	case key.Matches(msg, m.keys.toggleRegex):
+		m.search.regex = !m.search.regex
+		m.search.current = -1
+		return m.showMatch(), nil, true
+	case key.Matches(msg, m.keys.toggleCase):
+		m.search.caseSensitive = !m.search.caseSensitive
+		m.search.current = -1
+		return m.showMatch(), nil, true
+	case m.search.editing && key.Matches(msg, m.keys.send):
+		m.search.editing = false
+		m.search.input.Blur()
+		return m, nil, true
+	case m.search.editing:
+		pattern := m.search.input.Value()
+		m.search.input, cmd = m.search.input.Update(msg)
+		if m.search.input.Value() != pattern {
+			m.search.current = -1
+			m = m.showMatch()
+		}
+		return m, cmd, true
+	case key.Matches(msg, m.keys.search):
+		m.search.editing = true
+		return m, m.search.input.Focus(), true
+	case key.Matches(msg, m.keys.nextMatch):
+		m.search.current = m.currentMatch(m.mustMatches()) + 1
+		return m.showMatch(), nil, true
+	case key.Matches(msg, m.keys.prevMatch):
+		matches := m.mustMatches()
+		m.search.current = (m.currentMatch(matches) + len(matches) - 1) % max(1, len(matches))
+		return m.showMatch(), nil, true
+	}
+	return m, nil, false
+}
+
+// pattern compiles the search pattern.
+func (s searchState) pattern() (*regexp.Regexp, error) {
+	expr := s.input.Value()
+	if !s.regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if !s.caseSensitive {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// matches returns every match of the search in the conversation's entries,
+// in order.
+func (m model) matches(entries []entry) ([]match, error) {
+	if !m.search.active || m.search.input.Value() == "" {
+		return nil, nil
+	}
+	re, err := m.search.pattern()
+	if err != nil {
+		return nil, err
+	}
+	var matches []match
+	for i, e := range entries {
+		if e.speaker == "" || e.thinking {
+			continue
+		}
+		for _, loc := range re.FindAllStringIndex(e.text, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			matches = append(matches, match{entry: i, start: loc[0], end: loc[1]})
+		}
+	}
+	return matches, nil
+}
+
+// mustMatches returns the matches, or none if the pattern is invalid.
+func (m model) mustMatches() []match {
+	matches, _ := m.matches(m.entries())
+	return matches
+}
+
+// currentMatch returns the index of the active match.
+func (m model) currentMatch(matches []match) int {
+	if len(matches) == 0 {
+		return -1
+	}
+	current := m.search.current % len(matches)
+	if current < 0 {
+		current += len(matches)
+	}
+	return current
+}
+
+// showMatch scrolls the conversation to the active match.
+func (m model) showMatch() model {
+	entries := m.entries()
+	matches, _ := m.matches(entries)
+	current := m.currentMatch(matches)
+	if current < 0 {
+		return m
+	}
+	m.search.current = current
+	h := m.historyRows(entries, matches)
+	return m.scrollTo(h.matchRows[current], len(h.rows))
+}
+
+// view renders the search bar.
+func (s searchState) view(m model) string {
+	if !s.active {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\n")
+	b.WriteString(s.input.View())
+	b.WriteString("  ")
+	matches, err := m.matches(m.entries())
+	switch {
+	case err != nil:
+		b.WriteString(errorStyle.Render("invalid pattern"))
+	case len(matches) == 0 && s.input.Value() != "":
+		b.WriteString(errorStyle.Render("no matches"))
+	case len(matches) > 0:
+		fmt.Fprintf(&b, "%d/%d", m.currentMatch(matches)+1, len(matches))
+	}
+	b.WriteString("\n")
+	b.WriteString(faintStyle.Render(fmt.Sprintf("%s: regex %s  %s: match case %s",
+		m.keys.toggleRegex.Help().Key, onOff(s.regex),
+		m.keys.toggleCase.Help().Key, onOff(s.caseSensitive),
+	)))
+	return b.String()
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/x/ansi"
+	"go.akshayshah.org/attest"
+)
+
+// longConversation returns a model with a conversation too long to fit in a
+// small terminal.
+func longConversation(t *testing.T) model {
+	t.Helper()
+
+	m := initialModel(startFakeServer(t))
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 60, Height: 10})
+	m = next.(model)
+	m.hasIntroduced = true
+	m.name = "Alice"
+	m.addIntroduction([]string{"Hello Alice, I'm ELIZA."})
+	for i := range 20 {
+		if i == 3 {
+			m.addSentence("My cat is called Rex", statusSent)
+			m.addFromEliza(kindReply, time.Now(), "Tell me more about your CAT")
+			continue
+		}
+		m.addSentence(fmt.Sprintf("Sentence %d", i), statusSent)
+		m.addFromEliza(kindReply, time.Now(), fmt.Sprintf("Reply %d", i))
+	}
+	return m
+}
+
+func keyPress(t *testing.T, m model, keys ...tea.KeyPressMsg) model {
+	t.Helper()
+
+	for _, k := range keys {
+		next, _ := m.Update(k)
+		m = next.(model)
+	}
+	return m
+}
+
+func typed(text string) []tea.KeyPressMsg {
+	var keys []tea.KeyPressMsg
+	for _, r := range text {
+		keys = append(keys, tea.KeyPressMsg{Code: r, Text: string(r)})
+	}
+	return keys
+}
+
+func visible(m model) string {
+	return ansi.Strip(m.View().Content)
+}
+
+func TestConversationScrolls(t *testing.T) {
+	t.Parallel()
+
+	m := longConversation(t)
+	view := visible(m)
+	attest.Equal(t, strings.Count(view, "\n")+1, 10)
+	attest.Subsequence(t, view, "Eliza: Reply 19")
+	attest.False(t, strings.Contains(view, "Reply 14"))
+
+	m = keyPress(t, m, tea.KeyPressMsg{Code: tea.KeyPgUp})
+	view = visible(m)
+	attest.Subsequence(t, view, "Reply 15")
+	attest.False(t, strings.Contains(view, "Reply 19"))
+
+	m = keyPress(t, m, tea.KeyPressMsg{Code: tea.KeyPgDown}, tea.KeyPressMsg{Code: tea.KeyPgDown})
+	attest.Equal(t, m.scroll, 0)
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	m := longConversation(t)
+	m = keyPress(t, m, tea.KeyPressMsg{Code: 'f', Mod: tea.ModCtrl})
+	attest.True(t, m.search.editing)
+	m = keyPress(t, m, typed("cat")...)
+
+	// Matches are case-insensitive by default, and the latest is active.
+	view := visible(m)
+	attest.Subsequence(t, view, "Search: cat")
+	attest.Subsequence(t, view, "2/2")
+	attest.Subsequence(t, view, "Eliza: Tell me more about your CAT")
+	attest.False(t, strings.Contains(view, "Reply 19"))
+
+	m = keyPress(t, m, tea.KeyPressMsg{Code: tea.KeyEnter})
+	attest.False(t, m.search.editing)
+	m = keyPress(t, m, typed("N")...)
+	attest.Subsequence(t, visible(m), "1/2")
+	m = keyPress(t, m, typed("n")...)
+	attest.Subsequence(t, visible(m), "2/2")
+	m = keyPress(t, m, typed("n")...)
+	attest.Subsequence(t, visible(m), "1/2")
+
+	m = keyPress(t, m, tea.KeyPressMsg{Code: 'c', Mod: tea.ModAlt})
+	attest.True(t, m.search.caseSensitive)
+	attest.Subsequence(t, visible(m), "1/1")
+	attest.Subsequence(t, visible(m), "match case on")
+
+	// Speaker names aren't searched.
+	m = keyPress(t, m, tea.KeyPressMsg{Code: 'f', Mod: tea.ModCtrl})
+	for range 3 {
+		m = keyPress(t, m, tea.KeyPressMsg{Code: tea.KeyBackspace})
+	}
+	m = keyPress(t, m, typed("Alice")...)
+	attest.Subsequence(t, visible(m), "1/1")
+
+	m = keyPress(t, m, tea.KeyPressMsg{Code: 'r', Mod: tea.ModAlt})
+	attest.True(t, m.search.regex)
+	for range 5 {
+		m = keyPress(t, m, tea.KeyPressMsg{Code: tea.KeyBackspace})
+	}
+	m = keyPress(t, m, typed(`Reply 1\d`)...)
+	attest.Subsequence(t, visible(m), "10/10")
+	m = keyPress(t, m, typed("(")...)
+	attest.Subsequence(t, visible(m), "invalid pattern")
+
+	m = keyPress(t, m, tea.KeyPressMsg{Code: tea.KeyEscape})
+	attest.False(t, m.search.active)
+	attest.True(t, m.textInput.Focused())
+	attest.False(t, strings.Contains(visible(m), "Search:"))
+}
+
+func TestSearchCommand(t *testing.T) {
+	t.Parallel()
+
+	m := longConversation(t)
+	m = runSlash(t, m, "/search rex")
+	attest.True(t, m.search.active)
+	attest.False(t, m.search.editing)
+	attest.Subsequence(t, visible(m), "Alice: My cat is called Rex")
+	attest.Subsequence(t, visible(m), "1/1")
+}
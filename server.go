@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
+	"connectrpc.com/connect"
+	"connectrpc.com/grpcreflect"
+)
+
+func runServe(args []string) (err error) {
+	var opts commonOptions
+	fs := flag.NewFlagSet("eliza serve", flag.ExitOnError)
+	opts.register(fs)
+	addr := fs.String("addr", "localhost:8080", "`address` to listen on")
+	sessionFile := fs.String("sessions", "", "keep sessions in the file at `path`, rather than in memory")
+	sessionTTL := fs.Duration("session-ttl", defaultSessionTTL, "forget sessions that haven't been used for this long")
+	perSecond := fs.Float64("rate", 0, "calls and sentences each client may send per second, or 0 for no limit")
+	burst := fs.Int("burst", 10, "calls and sentences each client may send at once, within -rate")
+	maxStreams := fs.Int("max-streams", 0, "Converse streams each client may have open at once, or 0 for no limit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	interceptors, cleanup, err := opts.setup()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, cleanup())
+	}()
+	store, closeStore, err := openSessionStore(*sessionFile, *sessionTTL)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, closeStore())
+	}()
+
+	// The limits go innermost, so the calls they reject are still logged
+	// and traced.
+	limited := append(interceptors, newLimitInterceptor(*perSecond, *burst, *maxStreams))
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	// Converse is a bidi stream, which needs HTTP/2; without TLS, that
+	// means h2c.
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	server := &http.Server{
+		Handler:           newServeMux(store, connect.WithInterceptors(limited...)),
+		Protocols:         &protocols,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	slog.Info("serving", slog.String("addr", listener.Addr().String()))
+	fmt.Printf("serving ELIZA on h2c://%s\n", listener.Addr())
+	return serveUntilSignal(server, listener)
+}
+
+// serveUntilSignal serves on listener until an interrupt or SIGTERM, then
+// shuts the server down gracefully.
+func serveUntilSignal(server *http.Server, listener net.Listener) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("shutting down", errorAttrs(err)...)
+		}
+	}()
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// elizaServer is a local implementation of the ELIZA service, backed by
+// [doctor]. Calls with a [sessionHeader] share state through store;
+// without one, or without a store, a conversation is forgotten when its
+// stream ends.
+type elizaServer struct {
+	store sessionStore
+}
+
+var _ elizav1connect.ElizaServiceHandler = elizaServer{}
+
+// newServeMux returns a mux serving the local ELIZA service, keeping
+// sessions in store, along with the gRPC health service and server
+// reflection, so that tools like grpcurl and Kubernetes probes work against
+// it.
+func newServeMux(store sessionStore, opts ...connect.HandlerOption) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle(elizav1connect.NewElizaServiceHandler(elizaServer{store: store}, opts...))
+	mux.Handle(newHealthHandler(opts...))
+	reflector := grpcreflect.NewStaticReflector(elizav1connect.ElizaServiceName, healthServiceName)
+	mux.Handle(grpcreflect.NewHandlerV1(reflector, opts...))
+	mux.Handle(grpcreflect.NewHandlerV1Alpha(reflector, opts...))
+	return mux
+}
+
+// session returns the ID and state of the session a call belongs to. The ID
+// is empty if there's no session to keep.
+func (s elizaServer) session(header http.Header) (string, conversationState, error) {
+	id := header.Get(sessionHeader)
+	if id == "" || s.store == nil {
+		return "", conversationState{}, nil
+	}
+	state, _, err := s.store.load(id)
+	if err != nil {
+		return "", conversationState{}, connect.NewError(connect.CodeInternal, fmt.Errorf("loading session: %w", err))
+	}
+	return id, state, nil
+}
+
+// saveSession saves the state of the session with the given ID, unless
+// there's no session to keep.
+func (s elizaServer) saveSession(id string, state conversationState) error {
+	if id == "" {
+		return nil
+	}
+	if err := s.store.save(id, state); err != nil {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("saving session: %w", err))
+	}
+	return nil
+}
+
+func (s elizaServer) Say(
+	ctx context.Context,
+	req *connect.Request[elizav1.SayRequest],
+) (*connect.Response[elizav1.SayResponse], error) {
+	id, state, err := s.session(req.Header())
+	if err != nil {
+		return nil, err
+	}
+	d := state.doctor()
+	reply := d.reply(req.Msg.Sentence)
+	state.record(d, req.Msg.Sentence, reply)
+	if err := s.saveSession(id, state); err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&elizav1.SayResponse{
+		Sentence: reply,
+	}), nil
+}
+
+func (s elizaServer) Converse(
+	ctx context.Context,
+	stream *connect.BidiStream[elizav1.ConverseRequest, elizav1.ConverseResponse],
+) error {
+	id, state, err := s.session(stream.RequestHeader())
+	if err != nil {
+		return err
+	}
+	// Send the headers now, so the client knows the stream is up before
+	// the first reply.
+	if err := stream.Send(nil); err != nil {
+		return err
+	}
+	d := state.doctor()
+	for {
+		req, err := stream.Receive()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		reply := d.reply(req.Sentence)
+		// Save before replying, so a client that reconnects as
+		// soon as it has the reply finds it in the session.
+		state.record(d, req.Sentence, reply)
+		if err := s.saveSession(id, state); err != nil {
+			return err
+		}
+		if err := stream.Send(&elizav1.ConverseResponse{
+			Sentence: reply,
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s elizaServer) Introduce(
+	ctx context.Context,
+	req *connect.Request[elizav1.IntroduceRequest],
+	stream *connect.ServerStream[elizav1.IntroduceResponse],
+) error {
+	name := req.Msg.Name
+	if name == "" {
+		name = "Anonymous User"
+	}
+	id, state, err := s.session(req.Header())
+	if err != nil {
+		return err
+	}
+	sentences := introduction(name)
+	if len(state.History) > 0 {
+		sentences = welcomeBack(name, state.History[len(state.History)-1])
+	}
+	state.Name = name
+	if err := s.saveSession(id, state); err != nil {
+		return err
+	}
+	for _, sentence := range sentences {
+		if err := stream.Send(&elizav1.IntroduceResponse{
+			Sentence: sentence,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
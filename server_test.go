@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
+	tea "charm.land/bubbletea/v2"
+	"connectrpc.com/connect"
+	"connectrpc.com/grpcreflect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// startLocalServer starts the local ELIZA service in memory and returns a
+// client for it.
+func startLocalServer(t *testing.T) elizav1connect.ElizaServiceClient {
+	t.Helper()
+
+	server, err := memhttp.New(newServeMux(nil))
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() {
+		attest.Ok(t, server.Close())
+	})
+	return elizav1connect.NewElizaServiceClient(server.Client(), server.URL())
+}
+
+func TestLocalServerConversation(t *testing.T) {
+	t.Parallel()
+
+	m := initialModel(startLocalServer(t))
+	msg := m.introduce("Charlie")()
+	lines, ok := msg.(introductionMsg)
+	attest.True(t, ok, attest.Fatal(), attest.Sprintf("expected introductionMsg, got %T: %v", msg, msg))
+	attest.Equal(t, []string(lines), introduction("Charlie"))
+
+	m.hasIntroduced = true
+	m.name = "Charlie"
+	m = sendMessage(t, m, "My mother hates me")
+	m = sendMessage(t, m, "whatever")
+	m.closeConversation()
+
+	// The stream keeps the doctor's memory between messages.
+	attest.Equal(t, replies(m), []string{
+		"Tell me more about your family.",
+		"Let's discuss further why your mother hates you.",
+	})
+}
+
+func TestServeMuxHealthAndReflection(t *testing.T) {
+	t.Parallel()
+
+	server, err := memhttp.New(newServeMux(nil))
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() {
+		attest.Ok(t, server.Close())
+	})
+	ctx := context.Background()
+
+	for _, opts := range [][]connect.ClientOption{nil, {connect.WithGRPC()}} {
+		attest.Ok(t, checkHealth(ctx, server.Client(), server.URL(), opts...))
+	}
+	health := connect.NewClient[healthv1.HealthCheckRequest, healthv1.HealthCheckResponse](
+		server.Client(), server.URL()+healthCheckProcedure,
+	)
+	_, err = health.CallUnary(ctx, connect.NewRequest(&healthv1.HealthCheckRequest{Service: "acme.Widgets"}))
+	attest.Equal(t, connect.CodeOf(err), connect.CodeNotFound)
+	watch := connect.NewClient[healthv1.HealthCheckRequest, healthv1.HealthCheckResponse](
+		server.Client(), server.URL()+healthWatchProcedure, connect.WithGRPC(),
+	)
+	for service, want := range map[string]healthv1.HealthCheckResponse_ServingStatus{
+		elizav1connect.ElizaServiceName: healthv1.HealthCheckResponse_SERVING,
+		"acme.Widgets":                  healthv1.HealthCheckResponse_SERVICE_UNKNOWN,
+	} {
+		watchCtx, cancel := context.WithCancel(ctx)
+		updates, err := watch.CallServerStream(watchCtx, connect.NewRequest(&healthv1.HealthCheckRequest{Service: service}))
+		attest.Ok(t, err, attest.Fatal())
+		attest.True(t, updates.Receive(), attest.Sprintf("no status: %v", updates.Err()))
+		attest.Equal(t, updates.Msg().Status, want)
+		cancel()
+		attest.Ok(t, updates.Close())
+	}
+
+	// Servers without the health service fail the check.
+	err = checkHealth(ctx, server.Client(), server.URL()+"/missing")
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnimplemented)
+
+	stream := grpcreflect.NewClient(server.Client(), server.URL()).NewStream(ctx)
+	services, err := stream.ListServices()
+	attest.Ok(t, err)
+	attest.Equal(t, services, []protoreflect.FullName{elizav1connect.ElizaServiceName, healthServiceName})
+	files, err := stream.FileContainingSymbol(elizav1connect.ElizaServiceName)
+	attest.Ok(t, err)
+	attest.True(t, len(files) > 0)
+	_, err = stream.Close()
+	attest.Ok(t, err)
+}
+
+func TestLocalServerSessions(t *testing.T) {
+	t.Parallel()
+
+	server, err := memhttp.New(newServeMux(newMemoryStore(defaultSessionTTL)))
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() {
+		attest.Ok(t, server.Close())
+	})
+	client := elizav1connect.NewElizaServiceClient(server.Client(), server.URL())
+	ctx := context.Background()
+	converse := func(id string, sentences ...string) []string {
+		t.Helper()
+		stream := client.Converse(ctx)
+		if id != "" {
+			stream.RequestHeader().Set(sessionHeader, id)
+		}
+		var replies []string
+		for _, sentence := range sentences {
+			attest.Ok(t, stream.Send(&elizav1.ConverseRequest{Sentence: sentence}), attest.Fatal())
+			res, err := stream.Receive()
+			attest.Ok(t, err, attest.Fatal())
+			replies = append(replies, res.Sentence)
+		}
+		attest.Ok(t, stream.CloseRequest())
+		attest.Ok(t, stream.CloseResponse())
+		return replies
+	}
+	introduce := func(id, name string) []string {
+		t.Helper()
+		req := connect.NewRequest(&elizav1.IntroduceRequest{Name: name})
+		req.Header().Set(sessionHeader, id)
+		stream, err := client.Introduce(ctx, req)
+		attest.Ok(t, err, attest.Fatal())
+		var sentences []string
+		for stream.Receive() {
+			sentences = append(sentences, stream.Msg().Sentence)
+		}
+		attest.Ok(t, stream.Err())
+		return sentences
+	}
+
+	attest.Equal(t, introduce("alice", "Alice"), introduction("Alice"))
+	attest.Equal(t, converse("alice", "My mother hates me"), []string{"Tell me more about your family."})
+	// A new stream in the same session remembers the mother.
+	attest.Equal(t, converse("alice", "whatever"), []string{"Let's discuss further why your mother hates you."})
+	attest.Equal(t, introduce("alice", "Alice")[0], "Welcome back, Alice.")
+
+	// Other sessions, and calls without one, start afresh.
+	attest.Equal(t, converse("bob", "whatever"), []string{"Please tell me more."})
+	attest.Equal(t, converse("", "whatever"), []string{"Please tell me more."})
+
+	req := connect.NewRequest(&elizav1.SayRequest{Sentence: "My father is strict"})
+	req.Header().Set(sessionHeader, "carol")
+	_, err = client.Say(ctx, req)
+	attest.Ok(t, err)
+	attest.Equal(t, converse("carol", "whatever"), []string{"Let's discuss further why your father is strict."})
+}
+
+func TestTUIReconnectCarriesOn(t *testing.T) {
+	t.Parallel()
+
+	server, err := memhttp.New(newServeMux(newMemoryStore(defaultSessionTTL)))
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() {
+		attest.Ok(t, server.Close())
+	})
+	m := initialModel(elizav1connect.NewElizaServiceClient(server.Client(), server.URL()))
+	m = introduceTab(t, m, "Alice")
+	m = sendMessage(t, m, "My mother hates me")
+
+	// A new stream is in the same session, so the doctor remembers the
+	// mother...
+	m = runSlash(t, m, "/reconnect")
+	m = sendMessage(t, m, "whatever")
+	attest.Equal(t, replies(m)[1], "Let's discuss further why your mother hates you.")
+
+	// ...as does a Say call.
+	m = runSlash(t, m, "/mode unary")
+	m = sendMessage(t, m, "My father is strict")
+	m = runSlash(t, m, "/mode bidi")
+	m = sendMessage(t, m, "whatever")
+	attest.Subsequence(t, replies(m)[3], "your father is strict.")
+
+	// Introducing yourself again returns to the session...
+	m = runSlash(t, m, "/rename Alice")
+	attest.Equal(t, m.introduction()[0], "Welcome back, Alice.")
+
+	// ...but other tabs are other sessions.
+	next, _ := m.Update(tea.KeyPressMsg{Code: 't', Mod: tea.ModCtrl})
+	m = introduceTab(t, next.(model), "Alice")
+	attest.Equal(t, m.introduction(), introduction("Alice"))
+	m.closeConversations()
+}
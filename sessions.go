@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// sessionHeader is the request header naming the session a call belongs to.
+// Calls in the same session share the doctor's memory, even across
+// streams, so a reconnecting client carries on where it left off.
+const sessionHeader = "Eliza-Session-Id"
+
+const (
+	// maxHistory and maxMemory are how many exchanges, and remembered
+	// "my" sentences, a session keeps. ELIZA only needs recent context,
+	// and session IDs come from clients, so keeping everything would let
+	// any client grow the store without limit.
+	maxHistory = 20
+	maxMemory  = 10
+	// defaultSessionTTL is how long a session is kept after it was last
+	// saved.
+	defaultSessionTTL = 24 * time.Hour
+)
+
+// A sessionStore keeps conversation state between calls, keyed by session
+// ID, forgetting sessions that haven't been saved for its TTL. Calls in the
+// same session at the same time race: the last to save wins.
+type sessionStore interface {
+	// load returns the session's state, and whether there was any.
+	load(id string) (conversationState, bool, error)
+	save(id string, state conversationState) error
+}
+
+// conversationState is what's kept of a session between calls.
+type conversationState struct {
+	Name    string         `json:"name,omitempty"`
+	Memory  []string       `json:"memory,omitempty"`
+	Turns   map[string]int `json:"turns,omitempty"`
+	History []exchange     `json:"history,omitempty"`
+	// Saved is when the state was last saved, set by the store.
+	Saved time.Time `json:"saved"`
+}
+
+// An exchange is a sentence said to ELIZA and its reply.
+type exchange struct {
+	Sentence string `json:"sentence"`
+	Reply    string `json:"reply"`
+}
+
+// doctor returns a doctor that remembers the conversation so far.
+func (s conversationState) doctor() *doctor {
+	d := newDoctor()
+	d.memory = append(d.memory, s.Memory...)
+	maps.Copy(d.turns, s.Turns)
+	return d
+}
+
+// record adds an exchange with d to the state, dropping the oldest if
+// there are too many.
+func (s *conversationState) record(d *doctor, sentence, reply string) {
+	s.Memory = d.memory[max(0, len(d.memory)-maxMemory):]
+	s.Turns = d.turns
+	s.History = append(s.History, exchange{Sentence: sentence, Reply: reply})
+	s.History = s.History[max(0, len(s.History)-maxHistory):]
+}
+
+// expired reports whether a session saved at saved has outlived ttl.
+func expired(saved time.Time, ttl time.Duration, now time.Time) bool {
+	return now.Sub(saved) > ttl
+}
+
+// openSessionStore opens the sessions saved in the file at path, creating it
+// if need be, or keeps them in memory if path is empty. Sessions are kept
+// for ttl after they were last saved. The returned function closes the
+// file.
+func openSessionStore(path string, ttl time.Duration) (sessionStore, func() error, error) {
+	if path == "" {
+		return newMemoryStore(ttl), func() error { return nil }, nil
+	}
+	store, err := openBoltStore(path, ttl)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, store.db.Close, nil
+}
+
+// memoryStore keeps sessions in memory, for the life of the process.
+type memoryStore struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu sync.Mutex
+	// sessions holds each session's state as JSON, so that callers
+	// can't share slices or maps through it.
+	sessions map[string]memorySession
+	// swept is when expired sessions were last dropped.
+	swept time.Time
+}
+
+type memorySession struct {
+	data  []byte
+	saved time.Time
+}
+
+func newMemoryStore(ttl time.Duration) *memoryStore {
+	return &memoryStore{
+		ttl:      ttl,
+		now:      time.Now,
+		sessions: make(map[string]memorySession),
+	}
+}
+
+func (s *memoryStore) load(id string) (conversationState, bool, error) {
+	s.mu.Lock()
+	session, ok := s.sessions[id]
+	s.mu.Unlock()
+	if !ok || expired(session.saved, s.ttl, s.now()) {
+		return conversationState{}, false, nil
+	}
+	var state conversationState
+	err := json.Unmarshal(session.data, &state)
+	return state, true, err
+}
+
+func (s *memoryStore) save(id string, state conversationState) error {
+	now := s.now()
+	state.Saved = now
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = memorySession{data: data, saved: now}
+	// Sweeping a fraction of the TTL apart keeps saving cheap, while
+	// expired sessions are gone soon after they expire.
+	if now.Sub(s.swept) > s.ttl/4 {
+		maps.DeleteFunc(s.sessions, func(_ string, session memorySession) bool {
+			return expired(session.saved, s.ttl, now)
+		})
+		s.swept = now
+	}
+	return nil
+}
+
+// sessionsBucket is the bbolt bucket that holds sessions.
+var sessionsBucket = []byte("sessions")
+
+// boltStore keeps sessions in a bbolt file, so that they survive restarts.
+type boltStore struct {
+	db  *bbolt.DB
+	ttl time.Duration
+	now func() time.Time
+
+	mu sync.Mutex
+	// swept is when expired sessions were last dropped.
+	swept time.Time
+}
+
+func openBoltStore(path string, ttl time.Duration) (*boltStore, error) {
+	// Only one process can have the file open, so don't wait forever
+	// for another eliza serve to let go of it.
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening session file %s: %w", path, err)
+	}
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		return err
+	}); err != nil {
+		return nil, errors.Join(fmt.Errorf("opening session file %s: %w", path, err), db.Close())
+	}
+	return &boltStore{db: db, ttl: ttl, now: time.Now}, nil
+}
+
+func (s *boltStore) load(id string) (conversationState, bool, error) {
+	var (
+		state conversationState
+		ok    bool
+	)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(sessionsBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(data, &state)
+	})
+	if ok && expired(state.Saved, s.ttl, s.now()) {
+		return conversationState{}, false, err
+	}
+	return state, ok, err
+}
+
+func (s *boltStore) save(id string, state conversationState) error {
+	now := s.now()
+	state.Saved = now
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(id), data)
+	}); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	sweep := now.Sub(s.swept) > s.ttl/4
+	if sweep {
+		s.swept = now
+	}
+	s.mu.Unlock()
+	if !sweep {
+		return nil
+	}
+	return s.sweep(now)
+}
+
+// sweep deletes the sessions that have expired by now.
+func (s *boltStore) sweep(now time.Time) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		var ids [][]byte
+		bucket := tx.Bucket(sessionsBucket)
+		err := bucket.ForEach(func(id, data []byte) error {
+			var state conversationState
+			// Sessions that can't be read are no use either.
+			if json.Unmarshal(data, &state) != nil || expired(state.Saved, s.ttl, now) {
+				ids = append(ids, id)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := bucket.Delete(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
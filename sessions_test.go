@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+	"go.etcd.io/bbolt"
+)
+
+func TestSessionStores(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	bolt, err := openBoltStore(path, defaultSessionTTL)
+	attest.Ok(t, err, attest.Fatal())
+
+	for name, store := range map[string]sessionStore{"memory": newMemoryStore(defaultSessionTTL), "bolt": bolt} {
+		t.Run(name, func(t *testing.T) {
+			_, ok, err := store.load("alice")
+			attest.Ok(t, err)
+			attest.False(t, ok)
+
+			d := newDoctor()
+			state := conversationState{Name: "Alice"}
+			state.record(d, "My mother hates me", d.reply("My mother hates me"))
+			attest.Ok(t, store.save("alice", state))
+			// Changes after saving don't reach the store.
+			state.record(d, "whatever", d.reply("whatever"))
+
+			got, ok, err := store.load("alice")
+			attest.Ok(t, err)
+			attest.True(t, ok)
+			attest.Equal(t, got.Name, "Alice")
+			attest.Equal(t, got.History, []exchange{{"My mother hates me", "Tell me more about your family."}})
+			attest.Equal(t, got.doctor().reply("whatever"), "Let's discuss further why your mother hates you.")
+		})
+	}
+
+	// Sessions in a file survive reopening it.
+	attest.Ok(t, bolt.db.Close())
+	bolt, err = openBoltStore(path, defaultSessionTTL)
+	attest.Ok(t, err, attest.Fatal())
+	got, ok, err := bolt.load("alice")
+	attest.Ok(t, err)
+	attest.True(t, ok)
+	attest.Equal(t, got.Name, "Alice")
+	attest.Ok(t, bolt.db.Close())
+}
+
+func TestSessionHistoryIsCapped(t *testing.T) {
+	t.Parallel()
+
+	d := newDoctor()
+	var state conversationState
+	for i := range maxHistory + 5 {
+		sentence := fmt.Sprintf("My cat number %d is hungry", i)
+		state.record(d, sentence, d.reply(sentence))
+	}
+	attest.Equal(t, len(state.History), maxHistory)
+	attest.Equal(t, state.History[len(state.History)-1].Sentence, fmt.Sprintf("My cat number %d is hungry", maxHistory+4))
+	attest.True(t, len(state.Memory) <= maxMemory)
+}
+
+func TestSessionStoresForgetExpiredSessions(t *testing.T) {
+	t.Parallel()
+
+	const ttl = time.Hour
+	bolt, err := openBoltStore(filepath.Join(t.TempDir(), "sessions.db"), ttl)
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() { attest.Ok(t, bolt.db.Close()) })
+	memory := newMemoryStore(ttl)
+
+	now := time.Now()
+	clock := func() time.Time { return now }
+	bolt.now, memory.now = clock, clock
+	for name, store := range map[string]sessionStore{"memory": memory, "bolt": bolt} {
+		attest.Ok(t, store.save("alice", conversationState{Name: "Alice"}), attest.Sprintf("%s", name))
+	}
+
+	now = now.Add(ttl / 2)
+	for name, store := range map[string]sessionStore{"memory": memory, "bolt": bolt} {
+		attest.Ok(t, store.save("bob", conversationState{Name: "Bob"}), attest.Sprintf("%s", name))
+		_, ok, err := store.load("alice")
+		attest.Ok(t, err)
+		attest.True(t, ok, attest.Sprintf("%s forgot Alice too soon", name))
+	}
+
+	// Once Alice's session expires, it's gone, and the next save drops
+	// it from the store.
+	now = now.Add(ttl/2 + time.Second)
+	for name, store := range map[string]sessionStore{"memory": memory, "bolt": bolt} {
+		_, ok, err := store.load("alice")
+		attest.Ok(t, err)
+		attest.False(t, ok, attest.Sprintf("%s still has Alice", name))
+		attest.Ok(t, store.save("carol", conversationState{Name: "Carol"}))
+		got, ok, err := store.load("bob")
+		attest.Ok(t, err)
+		attest.True(t, ok)
+		attest.Equal(t, got.Name, "Bob")
+	}
+	attest.Equal(t, len(memory.sessions), 2)
+	attest.Ok(t, bolt.db.View(func(tx *bbolt.Tx) error {
+		attest.Equal(t, tx.Bucket(sessionsBucket).Stats().KeyN, 2)
+		return nil
+	}))
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	"charm.land/bubbles/v2/textinput"
+	tea "charm.land/bubbletea/v2"
+)
+
+// An endpoint is an ELIZA service that a tab can talk to.
+type endpoint struct {
+	url    string
+	client elizav1connect.ElizaServiceClient
+}
+
+// A sessionMsg is the result of a command started by the session with the
+// given ID, which may no longer be in the active tab.
+type sessionMsg struct {
+	id  int
+	msg tea.Msg
+}
+
+// forSession tags the message cmd produces, if any, with the active
+// session's ID.
+func (m model) forSession(cmd tea.Cmd) tea.Cmd {
+	id := m.id
+	return func() tea.Msg {
+		msg := cmd()
+		if msg == nil {
+			return nil
+		}
+		return sessionMsg{id: id, msg: msg}
+	}
+}
+
+// updateSession applies msg to the session it belongs to, even if that
+// session's tab isn't active.
+func (m model) updateSession(msg sessionMsg) (tea.Model, tea.Cmd) {
+	if msg.id == m.id {
+		return m.Update(msg.msg)
+	}
+	for i, s := range m.tabs {
+		if s.id != msg.id {
+			continue
+		}
+		// Swap the session in, rather than switching to its tab, which
+		// would end the active tab's search or selection.
+		active := m.active
+		m.tabs[active] = m.session
+		m.active, m.session = i, s
+		m.background = true
+		next, cmd := m.Update(msg.msg)
+		m = next.(model)
+		m.background = false
+		if m.err != nil {
+			// The program is quitting: stay on the failed tab so its
+			// error is the one shown.
+			return m, cmd
+		}
+		m.tabs[i] = m.session
+		m.active, m.session = active, m.tabs[active]
+		return m, cmd
+	}
+	// The session is gone.
+	return m, nil
+}
+
+// openTab opens a new tab, with its own introduction and conversation, on
+// the next endpoint, and switches to it.
+func (m model) openTab() (tea.Model, tea.Cmd) {
+	e := m.endpoints[len(m.tabs)%len(m.endpoints)]
+	m.tabs[m.active] = m.session
+	m.tabs = append(m.tabs, newSession(m.nextID, e))
+	m.nextID++
+	slog.Debug("tab opened", slog.Int("tab", len(m.tabs)), slog.String("url", e.url))
+	return m.switchTo(len(m.tabs) - 1), textinput.Blink
+}
+
+// switchTo makes the i'th tab active.
+func (m model) switchTo(i int) model {
+	if i == m.active {
+		return m
+	}
+	m.tabs[m.active] = m.session
+	m.tabs[m.active].textInput.Blur()
+	m.active = i
+	m.session = m.tabs[i]
+	m.search.active = false
+	m.selecting = false
+	m.textInput.Focus()
+	m.textInput.SetSuggestions(m.completions())
+	m.textInput.KeyMap.AcceptSuggestion = m.keys.complete
+	return m
+}
+
+// closeConversations closes the Converse streams of every tab.
+func (m model) closeConversations() {
+	m.tabs[m.active] = m.session
+	for _, s := range m.tabs {
+		s.closeConversation()
+	}
+}
+
+// waitForConversations waits for the Converse streams of every tab to end,
+// once they've been closed. It mustn't be called from the event loop, which
+// the streams' receive loops may be waiting to post to.
+func (m model) waitForConversations() {
+	m.tabs[m.active] = m.session
+	for _, s := range m.tabs {
+		if s.conversation != nil {
+			s.conversation.wait()
+		}
+	}
+}
+
+// tabBar renders the list of tabs, or nothing if there's only one.
+func (m model) tabBar() string {
+	if len(m.tabs) < 2 {
+		return ""
+	}
+	labels := make([]string, len(m.tabs))
+	for i, s := range m.tabs {
+		if i == m.active {
+			s = m.session
+		}
+		label := s.name
+		if label == "" {
+			label = "new"
+		}
+		if len(m.endpoints) > 1 {
+			label += " @ " + endpointHost(s.url)
+		}
+		label = fmt.Sprintf("%d: %s", i+1, label)
+		if i == m.active {
+			label = "[" + label + "]"
+		} else {
+			label = " " + label + " "
+		}
+		labels[i] = label
+	}
+	return strings.Join(labels, "│") + "\n\n"
+}
+
+func endpointHost(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return rawURL
+}
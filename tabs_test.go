@@ -0,0 +1,161 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"go.akshayshah.org/attest"
+)
+
+// introduceTab drives the active tab's introduction through Update.
+func introduceTab(t *testing.T, m model, name string) model {
+	t.Helper()
+
+	m.textInput.SetValue(name)
+	next, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	m = next.(model)
+	next, _ = m.Update(cmd())
+	return next.(model)
+}
+
+func TestTabsHaveSeparateSessions(t *testing.T) {
+	t.Parallel()
+
+	client, handler := startFakeServerWithHandler(t)
+	m := initialModel(client)
+	m = introduceTab(t, m, "Alice")
+	m = sendMessage(t, m, "hello from alice")
+
+	next, _ := m.Update(tea.KeyPressMsg{Code: 't', Mod: tea.ModCtrl})
+	m = next.(model)
+	attest.Equal(t, len(m.tabs), 2)
+	attest.Equal(t, m.active, 1)
+	attest.False(t, m.hasIntroduced)
+
+	m = introduceTab(t, m, "Bob")
+	m = sendMessage(t, m, "hello from bob")
+	attest.Equal(t, m.name, "Bob")
+	attest.Equal(t, said(m), []string{"hello from bob"})
+
+	next, _ = m.Update(tea.KeyPressMsg{Code: tea.KeyTab, Mod: tea.ModCtrl})
+	m = next.(model)
+	attest.Equal(t, m.active, 0)
+	attest.Equal(t, m.name, "Alice")
+	attest.Equal(t, said(m), []string{"hello from alice"})
+	attest.Subsequence(t, m.View().Content, "[1: Alice]│ 2: Bob ")
+
+	// Each tab has its own stream, and quitting closes both.
+	attest.Equal(t, handler.converseCalls.Load(), int32(2))
+	_, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	attest.True(t, cmd != nil)
+	for range 2 {
+		select {
+		case <-handler.converseDone:
+		case <-time.After(3 * time.Second):
+			t.Fatal("Converse handler still running after quit: stream was never closed")
+		}
+	}
+}
+
+func TestReplyReachesInactiveTab(t *testing.T) {
+	t.Parallel()
+
+	m, in := withInbox(initialModel(startFakeServer(t)))
+	m = introduceTab(t, m, "Alice")
+
+	// Send from the first tab, then switch away before the reply arrives.
+	m.textInput.SetValue("are you there?")
+	next, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	m = next.(model)
+	next, _ = m.Update(tea.KeyPressMsg{Code: 't', Mod: tea.ModCtrl})
+	m = next.(model)
+
+	attest.Zero(t, cmd())
+	next, _ = m.Update(in.next(t))
+	m = next.(model)
+	attest.Equal(t, m.active, 1)
+	attest.Equal(t, len(replies(m)), 0)
+
+	next, _ = m.Update(tea.KeyPressMsg{Code: tea.KeyTab, Mod: tea.ModCtrl | tea.ModShift})
+	m = next.(model)
+	attest.Equal(t, m.active, 0)
+	attest.False(t, m.waitingForResponse)
+	attest.Equal(t, len(replies(m)), 1)
+	m.closeConversations()
+}
+
+func TestReplyInInactiveTabLeavesSearchAndSelection(t *testing.T) {
+	t.Parallel()
+
+	m, in := withInbox(initialModel(startFakeServer(t)))
+	m = introduceTab(t, m, "Alice")
+	var one tea.Cmd
+	for i, sentence := range []string{"one", "two", "three", "four"} {
+		m.textInput.SetValue(sentence)
+		next, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+		m = next.(model)
+		if i == 0 {
+			one = cmd
+		}
+	}
+
+	next, _ := m.Update(tea.KeyPressMsg{Code: 't', Mod: tea.ModCtrl})
+	m = next.(model)
+	m = introduceTab(t, m, "Bob")
+	for _, sentence := range []string{"hello", "how are you", "goodbye"} {
+		m = sendMessage(t, m, sentence)
+	}
+	next, _ = m.Update(tea.KeyPressMsg{Code: 'f', Mod: tea.ModCtrl})
+	m = next.(model)
+	attest.True(t, m.search.active)
+
+	// The first tab's reply sends its next queued sentence, while the
+	// second tab's search carries on.
+	attest.Zero(t, one())
+	next, two := m.Update(in.next(t))
+	m = next.(model)
+	attest.Equal(t, m.active, 1)
+	attest.True(t, m.search.active)
+	attest.True(t, m.search.input.Focused())
+	attest.False(t, m.textInput.Focused())
+
+	next, _ = m.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	m = next.(model)
+	next, _ = m.Update(tea.KeyPressMsg{Code: 's', Mod: tea.ModCtrl})
+	m = next.(model)
+	attest.True(t, m.selecting)
+	selected := m.selected
+
+	// Sending the first tab's queued sentences leaves the second tab's
+	// selection where it was.
+	for range 3 {
+		attest.True(t, two != nil, attest.Fatal())
+		attest.Zero(t, two())
+		next, two = m.Update(in.next(t))
+		m = next.(model)
+		attest.True(t, m.selecting)
+		attest.Equal(t, m.selected, selected)
+		attest.False(t, m.textInput.Focused())
+	}
+	attest.Equal(t, len(replies(m)), 3)
+	attest.Equal(t, len(replies(m.switchTo(0))), 4)
+	m.closeConversations()
+}
+
+func TestNewTabsCycleThroughEndpoints(t *testing.T) {
+	t.Parallel()
+
+	m := initialModelWithEndpoints([]endpoint{
+		{url: "https://a.example.com", client: startFakeServer(t)},
+		{url: "h2c://localhost:8080", client: startFakeServer(t)},
+	})
+	for range 2 {
+		next, _ := m.Update(tea.KeyPressMsg{Code: 't', Mod: tea.ModCtrl})
+		m = next.(model)
+	}
+	attest.Equal(t, m.tabs[0].url, "https://a.example.com")
+	attest.Equal(t, m.tabs[1].url, "h2c://localhost:8080")
+	attest.Equal(t, m.url, "https://a.example.com")
+	attest.Subsequence(t, m.tabBar(), "[3: new @ a.example.com]")
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/otelconnect"
+	"go.opentelemetry.io/contrib/exporters/autoexport"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "go.vanburen.xyz/eliza"
+
+// setupTelemetry installs global OpenTelemetry tracer and meter providers.
+//
+// If file is non-empty, spans and metrics are written to it as JSON, which
+// works without network access. Otherwise, if any of the standard OTEL_*
+// exporter environment variables are set, exporters are configured from them
+// (OTLP by default). If neither applies, telemetry stays disabled.
+//
+// The returned function flushes and shuts down the providers.
+func setupTelemetry(ctx context.Context, file string) (func(context.Context) error, error) {
+	var (
+		spanExporter sdktrace.SpanExporter
+		metricReader sdkmetric.Reader
+		closers      []func() error
+	)
+	switch {
+	case file != "":
+		f, err := os.Create(file)
+		if err != nil {
+			return nil, err
+		}
+		closers = append(closers, f.Close)
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			return nil, errors.Join(err, f.Close())
+		}
+		metricExporter, err := stdoutmetric.New(stdoutmetric.WithWriter(f))
+		if err != nil {
+			return nil, errors.Join(err, f.Close())
+		}
+		metricReader = sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(10*time.Second))
+	case otelConfigured():
+		var err error
+		spanExporter, err = autoexport.NewSpanExporter(ctx)
+		if err != nil {
+			return nil, err
+		}
+		metricReader, err = autoexport.NewMetricReader(ctx)
+		if err != nil {
+			return nil, errors.Join(err, spanExporter.Shutdown(ctx))
+		}
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(spanExporter))
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(metricReader))
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetMeterProvider(meterProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return func(ctx context.Context) error {
+		err := errors.Join(
+			tracerProvider.Shutdown(ctx),
+			meterProvider.Shutdown(ctx),
+		)
+		for _, closeFn := range closers {
+			err = errors.Join(err, closeFn())
+		}
+		return err
+	}, nil
+}
+
+// otelConfigured reports whether the environment asks for telemetry to be
+// exported.
+func otelConfigured() bool {
+	if os.Getenv("OTEL_SDK_DISABLED") == "true" {
+		return false
+	}
+	for _, key := range []string{
+		"OTEL_TRACES_EXPORTER",
+		"OTEL_METRICS_EXPORTER",
+		"OTEL_EXPORTER_OTLP_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT",
+	} {
+		if os.Getenv(key) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// newTelemetryInterceptors returns interceptors that record a span and
+// latency and status code metrics for every RPC, plus a child span for every
+// message sent or received on a stream. They can be used on both clients and
+// handlers.
+func newTelemetryInterceptors(tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) ([]connect.Interceptor, error) {
+	rpcInterceptor, err := otelconnect.NewInterceptor(
+		otelconnect.WithTracerProvider(tracerProvider),
+		otelconnect.WithMeterProvider(meterProvider),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return []connect.Interceptor{
+		rpcInterceptor,
+		&messageInterceptor{tracer: tracerProvider.Tracer(instrumentationName)},
+	}, nil
+}
+
+// messageInterceptor records a span for each message sent or received on a
+// streaming RPC. It must run inside the otelconnect interceptor so the
+// message spans are children of the RPC span.
+type messageInterceptor struct {
+	tracer trace.Tracer
+}
+
+func (i *messageInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return next
+}
+
+func (i *messageInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		return &messageClientConn{
+			StreamingClientConn: next(ctx, spec),
+			ctx:                 ctx,
+			tracer:              i.tracer,
+		}
+	}
+}
+
+func (i *messageInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		return next(ctx, &messageHandlerConn{
+			StreamingHandlerConn: conn,
+			ctx:                  ctx,
+			tracer:               i.tracer,
+		})
+	}
+}
+
+type messageClientConn struct {
+	connect.StreamingClientConn
+
+	ctx    context.Context
+	tracer trace.Tracer
+}
+
+func (c *messageClientConn) Send(msg any) error {
+	return traceMessage(c.ctx, c.tracer, c.Spec(), "send", func() error {
+		return c.StreamingClientConn.Send(msg)
+	})
+}
+
+func (c *messageClientConn) Receive(msg any) error {
+	return traceMessage(c.ctx, c.tracer, c.Spec(), "receive", func() error {
+		return c.StreamingClientConn.Receive(msg)
+	})
+}
+
+type messageHandlerConn struct {
+	connect.StreamingHandlerConn
+
+	ctx    context.Context
+	tracer trace.Tracer
+}
+
+func (c *messageHandlerConn) Send(msg any) error {
+	return traceMessage(c.ctx, c.tracer, c.Spec(), "send", func() error {
+		return c.StreamingHandlerConn.Send(msg)
+	})
+}
+
+func (c *messageHandlerConn) Receive(msg any) error {
+	return traceMessage(c.ctx, c.tracer, c.Spec(), "receive", func() error {
+		return c.StreamingHandlerConn.Receive(msg)
+	})
+}
+
+// traceMessage runs fn inside a span named after the procedure and
+// direction. End of stream is not recorded as an error.
+func traceMessage(ctx context.Context, tracer trace.Tracer, spec connect.Spec, direction string, fn func() error) error {
+	_, span := tracer.Start(ctx, strings.TrimPrefix(spec.Procedure, "/")+"/"+direction,
+		trace.WithAttributes(attribute.String("rpc.message.type", direction)),
+	)
+	defer span.End()
+	err := fn()
+	if err != nil && !errors.Is(err, io.EOF) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
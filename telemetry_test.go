@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTelemetryInterceptorsRecordSpans(t *testing.T) {
+	t.Parallel()
+
+	spanRecorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+	metricReader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(metricReader))
+
+	interceptors, err := newTelemetryInterceptors(tracerProvider, meterProvider)
+	attest.Ok(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(elizav1connect.NewElizaServiceHandler(&fakeElizaServiceHandler{}))
+	server, err := memhttp.New(mux)
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() {
+		attest.Ok(t, server.Close())
+	})
+	client := elizav1connect.NewElizaServiceClient(
+		server.Client(),
+		"https://example.com",
+		connect.WithInterceptors(interceptors...),
+	)
+
+	m := initialModel(client)
+	msg := m.introduce("User")()
+	_, ok := msg.(introductionMsg)
+	attest.True(t, ok, attest.Sprintf("expected introductionMsg, got %T: %v", msg, msg))
+
+	m.hasIntroduced = true
+	m.name = "User"
+	m = sendMessage(t, m, "hello")
+	m.closeConversations()
+	m.waitForConversations()
+
+	names := make(map[string]int)
+	var rpcSpan sdktrace.ReadOnlySpan
+	for _, span := range spanRecorder.Ended() {
+		names[span.Name()]++
+		if span.Name() == "connectrpc.eliza.v1.ElizaService/Converse" {
+			rpcSpan = span
+		}
+	}
+	attest.Equal(t, names["connectrpc.eliza.v1.ElizaService/Introduce"], 1)
+	attest.Equal(t, names["connectrpc.eliza.v1.ElizaService/Converse"], 1)
+	// Introduce streams three sentences, then end of stream.
+	attest.Equal(t, names["connectrpc.eliza.v1.ElizaService/Introduce/receive"], 4)
+	attest.Equal(t, names["connectrpc.eliza.v1.ElizaService/Converse/send"], 1)
+	// Converse receives the reply, then end of stream.
+	attest.Equal(t, names["connectrpc.eliza.v1.ElizaService/Converse/receive"], 2)
+
+	// Message spans are children of the RPC span.
+	attest.True(t, rpcSpan != nil, attest.Fatal())
+	for _, span := range spanRecorder.Ended() {
+		if span.Name() == "connectrpc.eliza.v1.ElizaService/Converse/send" {
+			attest.Equal(t, span.Parent().SpanID(), rpcSpan.SpanContext().SpanID())
+		}
+	}
+
+	var metrics metricdata.ResourceMetrics
+	attest.Ok(t, metricReader.Collect(context.Background(), &metrics))
+	var metricNames []string
+	for _, scope := range metrics.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			metricNames = append(metricNames, m.Name)
+		}
+	}
+	attest.Contains(t, metricNames, "rpc.client.call.duration")
+}
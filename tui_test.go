@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/x/ansi"
+	"go.akshayshah.org/attest"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// frames collects every frame rendered by a program, with styling removed.
+type frames struct {
+	mu     sync.Mutex
+	frames []string
+}
+
+func (f *frames) add(frame string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.frames = append(f.frames, frame)
+}
+
+func (f *frames) last() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.frames) == 0 {
+		return ""
+	}
+	return f.frames[len(f.frames)-1]
+}
+
+// output collects what a program writes to the terminal.
+type output struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(p)
+}
+
+// String returns everything written so far, with styling and cursor
+// movement removed.
+func (o *output) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return ansi.Strip(o.buf.String())
+}
+
+// framedModel wraps a model, recording each frame it renders.
+type framedModel struct {
+	model
+	frames *frames
+}
+
+func (m framedModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.model.Update(msg)
+	m.model = next.(model)
+	return m, cmd
+}
+
+func (m framedModel) View() tea.View {
+	v := m.model.View()
+	m.frames.add(ansi.Strip(v.Content))
+	return v
+}
+
+// tuiHarness runs a real Bubble Tea program with a fixed terminal size and
+// no terminal input, driven by scripted key presses.
+type tuiHarness struct {
+	t       *testing.T
+	program *tea.Program
+	frames  *frames
+	output  *output
+	done    chan error
+}
+
+func startTUI(t *testing.T, m model) *tuiHarness {
+	t.Helper()
+
+	h := &tuiHarness{
+		t:      t,
+		frames: &frames{},
+		output: &output{},
+		done:   make(chan error, 1),
+	}
+	m.program = postFunc(func(msg tea.Msg) {
+		h.program.Send(msg)
+	})
+	h.program = tea.NewProgram(
+		framedModel{model: m, frames: h.frames},
+		tea.WithContext(t.Context()),
+		tea.WithInput(nil),
+		tea.WithOutput(h.output),
+		tea.WithWindowSize(80, 24),
+		tea.WithoutSignals(),
+	)
+	go func() {
+		_, err := h.program.Run()
+		h.done <- err
+	}()
+	t.Cleanup(func() {
+		h.program.Kill()
+		<-h.done
+	})
+	return h
+}
+
+// typeText sends a key press for each rune in text.
+func (h *tuiHarness) typeText(text string) {
+	for _, r := range text {
+		h.program.Send(tea.KeyPressMsg{Code: r, Text: string(r)})
+	}
+}
+
+func (h *tuiHarness) press(code rune) {
+	h.program.Send(tea.KeyPressMsg{Code: code})
+}
+
+// waitFor waits for a rendered frame satisfying cond, and returns it.
+func (h *tuiHarness) waitFor(description string, cond func(frame string) bool) string {
+	h.t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if frame := h.frames.last(); cond(frame) {
+			return frame
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.t.Fatalf("timed out waiting for %s; last frame:\n%s", description, h.frames.last())
+	return ""
+}
+
+// waitForOutput waits until everything the program has written satisfies
+// cond, and returns it.
+func (h *tuiHarness) waitForOutput(description string, cond func(output string) bool) string {
+	h.t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if output := h.output.String(); cond(output) {
+			return output
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.t.Fatalf("timed out waiting for %s; output:\n%s", description, h.output.String())
+	return ""
+}
+
+// quit presses escape and waits for the program to exit.
+func (h *tuiHarness) quit() {
+	h.t.Helper()
+
+	h.press(tea.KeyEscape)
+	select {
+	case err := <-h.done:
+		attest.Ok(h.t, err)
+		// Let the cleanup function's receive succeed.
+		h.done <- nil
+	case <-time.After(5 * time.Second):
+		h.t.Fatal("program did not exit")
+	}
+}
+
+// assertGolden compares frame to testdata/name.golden, or rewrites the file
+// if -update is set.
+func assertGolden(t *testing.T, name, frame string) {
+	t.Helper()
+
+	// Trailing spaces depend on padding, not on content.
+	lines := strings.Split(frame, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	frame = strings.Join(lines, "\n")
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		attest.Ok(t, os.MkdirAll("testdata", 0o755))
+		attest.Ok(t, os.WriteFile(path, []byte(frame), 0o644))
+		return
+	}
+	want, err := os.ReadFile(path)
+	attest.Ok(t, err, attest.Fatal(), attest.Sprintf("run go test -update to create golden files"))
+	attest.Equal(t, frame, string(want))
+}
+
+// promptReady reports whether frame shows the text input, meaning nothing is
+// pending.
+func promptReady(frame string) bool {
+	lines := strings.Split(strings.TrimRight(frame, "\n "), "\n")
+	return strings.HasPrefix(lines[len(lines)-1], ">")
+}
+
+func TestTUIGolden(t *testing.T) {
+	t.Parallel()
+
+	h := startTUI(t, initialModel(startFakeServer(t)))
+
+	h.typeText("Charlie")
+	assertGolden(t, "introduction", h.waitFor("name to be typed", func(frame string) bool {
+		return strings.Contains(frame, "Charlie")
+	}))
+
+	h.press(tea.KeyEnter)
+	assertGolden(t, "introduced", h.waitFor("introduction", func(frame string) bool {
+		return strings.Contains(frame, "I'm here to help you.") && promptReady(frame)
+	}))
+
+	h.typeText("I feel fine")
+	h.press(tea.KeyEnter)
+	h.waitFor("response", func(frame string) bool {
+		return strings.Contains(frame, "Tell me more.") && promptReady(frame)
+	})
+	h.typeText("Goodbye")
+	h.press(tea.KeyEnter)
+	assertGolden(t, "conversation", h.waitFor("second response", func(frame string) bool {
+		return strings.Contains(frame, `"Goodbye"`) && promptReady(frame)
+	}))
+
+	h.quit()
+}
@@ -0,0 +1,503 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"buf.build/gen/go/connectrpc/eliza/connectrpc/go/connectrpc/eliza/v1/elizav1connect"
+	elizav1 "buf.build/gen/go/connectrpc/eliza/protocolbuffers/go/connectrpc/eliza/v1"
+	"connectrpc.com/connect"
+)
+
+// webFiles holds the chat page served by eliza web.
+//
+//go:embed web
+var webFiles embed.FS
+
+func runWeb(args []string) (err error) {
+	var opts commonOptions
+	fs := flag.NewFlagSet("eliza web", flag.ExitOnError)
+	opts.register(fs)
+	addr := fs.String("addr", "localhost:8081", "`address` to listen on")
+	baseURL := fs.String("url", defaultURL, "base `URL` of the ELIZA service")
+	idleTimeout := fs.Duration("idle-timeout", defaultWebIdleTimeout, "end sessions that no page has been listening to for `duration`")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	interceptors, cleanup, err := opts.setup()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, cleanup())
+	}()
+
+	client, closeClient, err := newRemoteClient(*baseURL, connect.WithInterceptors(interceptors...))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, closeClient())
+	}()
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	web := newWebServer(client)
+	web.idleTimeout = *idleTimeout
+	defer web.closeAll()
+	expiring, stopExpiring := context.WithCancel(context.Background())
+	defer stopExpiring()
+	go web.expireIdle(expiring)
+	server := &http.Server{
+		Handler:           web.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Event streams only end when their session does, so they'd hold up
+	// a graceful shutdown.
+	server.RegisterOnShutdown(web.closeAll)
+
+	slog.Info("serving web UI", slog.String("addr", listener.Addr().String()), slog.String("url", *baseURL))
+	fmt.Printf("serving the web UI on http://%s\n", listener.Addr())
+	return serveUntilSignal(server, listener)
+}
+
+// webServer serves a chat page, and proxies each browser session's
+// conversation to the ELIZA service. The page sends sentences with POST
+// requests, and gets ELIZA's replies as server-sent events.
+type webServer struct {
+	client elizav1connect.ElizaServiceClient
+	// idleTimeout is how long a session lasts without a page listening
+	// to its events. Pages end their sessions when they're closed, but
+	// not if they crash.
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*webSession
+}
+
+// A webLine is a line of a browser session's conversation. Lines with an
+// error instead of a speaker report a failed call to the ELIZA service.
+type webLine struct {
+	Speaker string `json:"speaker,omitempty"`
+	Text    string `json:"text,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// webTranscript is the JSON download of a browser session's conversation.
+type webTranscript struct {
+	Name  string    `json:"name"`
+	Lines []webLine `json:"lines"`
+}
+
+// defaultWebIdleTimeout is how long a session lasts without a page
+// listening, by default. Browsers reconnect dropped event streams within
+// seconds.
+const defaultWebIdleTimeout = 5 * time.Minute
+
+func newWebServer(client elizav1connect.ElizaServiceClient) *webServer {
+	return &webServer{
+		client:      client,
+		idleTimeout: defaultWebIdleTimeout,
+		sessions:    make(map[string]*webSession),
+	}
+}
+
+func (s *webServer) handler() http.Handler {
+	static, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServerFS(static))
+	mux.HandleFunc("POST /api/sessions", s.startSession)
+	mux.HandleFunc("POST /api/sessions/{id}/messages", s.withSession(s.sendMessage))
+	mux.HandleFunc("GET /api/sessions/{id}/events", s.withSession(s.streamEvents))
+	mux.HandleFunc("GET /api/sessions/{id}/transcript", s.withSession(s.downloadTranscript))
+	mux.HandleFunc("DELETE /api/sessions/{id}", s.withSession(s.endSession))
+	return mux
+}
+
+// startSession starts a conversation for the name in the request, and
+// responds with the session's ID. The introduction follows as events.
+func (s *webServer) startSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	session := newWebSession(s.client, name)
+	id := rand.Text()
+	s.mu.Lock()
+	s.sessions[id] = session
+	s.mu.Unlock()
+	slog.Info("web session started", slog.String("session", id))
+	go session.introduce()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]string{"id": id}); err != nil {
+		slog.Warn("writing web session ID", errorAttrs(err)...)
+	}
+}
+
+// withSession looks up the session named by the request's path, responding
+// with 404 if there isn't one.
+func (s *webServer) withSession(handle func(http.ResponseWriter, *http.Request, string, *webSession)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		s.mu.Lock()
+		session, ok := s.sessions[id]
+		s.mu.Unlock()
+		if !ok {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		handle(w, r, id, session)
+	}
+}
+
+func (s *webServer) sendMessage(w http.ResponseWriter, r *http.Request, _ string, session *webSession) {
+	var req struct {
+		Sentence string `json:"sentence"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Sentence) == "" {
+		http.Error(w, "sentence is required", http.StatusBadRequest)
+		return
+	}
+	if err := session.say(req.Sentence); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// streamEvents sends the session's lines as server-sent events, as they're
+// added, until the session ends. Each event's ID is the number of lines sent
+// so far, so a reconnecting browser picks up where it left off.
+func (s *webServer) streamEvents(w http.ResponseWriter, r *http.Request, _ string, session *webSession) {
+	next, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+	defer session.listen()()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	rc := http.NewResponseController(w)
+	for {
+		lines, changed, closed := session.since(next)
+		for _, line := range lines {
+			data, err := json.Marshal(line)
+			if err != nil {
+				slog.Warn("encoding web event", errorAttrs(err)...)
+				return
+			}
+			next++
+			fmt.Fprintf(w, "id: %d\ndata: %s\n\n", next, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		if closed {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *webServer) downloadTranscript(w http.ResponseWriter, r *http.Request, _ string, session *webSession) {
+	lines, _, _ := session.since(0)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="eliza-transcript.json"`)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(webTranscript{Name: session.name, Lines: lines}); err != nil {
+		slog.Warn("writing web transcript", errorAttrs(err)...)
+	}
+}
+
+func (s *webServer) endSession(w http.ResponseWriter, r *http.Request, id string, session *webSession) {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	session.close()
+	slog.Info("web session ended", slog.String("session", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// closeAll ends every session.
+func (s *webServer) closeAll() {
+	s.mu.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*webSession)
+	s.mu.Unlock()
+	for _, session := range sessions {
+		session.close()
+	}
+}
+
+// expireIdle ends idle sessions, checking every so often until ctx is
+// done.
+func (s *webServer) expireIdle(ctx context.Context) {
+	ticker := time.NewTicker(max(time.Second, s.idleTimeout/4))
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.expireIdleAt(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// expireIdleAt ends the sessions that, at now, have gone without a page
+// listening for longer than the idle timeout.
+func (s *webServer) expireIdleAt(now time.Time) {
+	var expired []*webSession
+	s.mu.Lock()
+	for id, session := range s.sessions {
+		if session.idle(now) > s.idleTimeout {
+			delete(s.sessions, id)
+			expired = append(expired, session)
+			slog.Info("web session expired", slog.String("session", id))
+		}
+	}
+	s.mu.Unlock()
+	for _, session := range expired {
+		session.close()
+	}
+}
+
+// decodeJSON decodes the request's body into v, responding with 400 if it
+// can't.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(v); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// A webSession is one browser's conversation, over its own Converse stream.
+type webSession struct {
+	client elizav1connect.ElizaServiceClient
+	name   string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// sendMu is held while introducing and sending, so that sentences
+	// are sent in order, after the introduction.
+	sendMu       sync.Mutex
+	conversation *connect.BidiStreamForClient[elizav1.ConverseRequest, elizav1.ConverseResponse]
+
+	mu    sync.Mutex
+	lines []webLine
+	// changed is closed, and replaced, whenever a line is added or the
+	// session ends.
+	changed chan struct{}
+	closed  bool
+	// listeners counts the pages streaming the session's events, and
+	// idleSince is when the last one stopped.
+	listeners int
+	idleSince time.Time
+}
+
+func newWebSession(client elizav1connect.ElizaServiceClient, name string) *webSession {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &webSession{
+		client:    client,
+		name:      name,
+		ctx:       ctx,
+		cancel:    cancel,
+		changed:   make(chan struct{}),
+		idleSince: time.Now(),
+	}
+}
+
+// introduce introduces the session's user to ELIZA.
+func (s *webSession) introduce() {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	stream, err := s.client.Introduce(s.ctx, connect.NewRequest(&elizav1.IntroduceRequest{Name: s.name}))
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	defer stream.Close()
+	for stream.Receive() {
+		s.add(webLine{Speaker: "Eliza", Text: stream.Msg().Sentence})
+	}
+	if err := stream.Err(); err != nil {
+		s.fail(err)
+	}
+}
+
+// say sends sentence over the session's Converse stream, opening it if
+// there isn't one. The reply is added by the stream's receive loop.
+func (s *webSession) say(sentence string) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	if s.ctx.Err() != nil {
+		return errors.New("the session has ended")
+	}
+	first := s.conversation == nil
+	if first {
+		s.conversation = s.client.Converse(s.ctx)
+	}
+	s.add(webLine{Speaker: s.name, Text: sentence})
+	if err := s.conversation.Send(&elizav1.ConverseRequest{Sentence: sentence}); err != nil {
+		if first {
+			if errors.Is(err, io.EOF) {
+				// The real error comes from receiving.
+				_, err = s.conversation.Receive()
+			}
+			s.fail(err)
+		}
+		// Otherwise, the receive loop reports the stream's error. Either
+		// way, the next sentence opens a new stream.
+		s.dropConversation(s.conversation)
+		return err
+	}
+	if first {
+		go s.receive(s.conversation)
+	}
+	return nil
+}
+
+// receive adds ELIZA's replies on the Converse stream until it ends.
+func (s *webSession) receive(conversation *connect.BidiStreamForClient[elizav1.ConverseRequest, elizav1.ConverseResponse]) {
+	for {
+		res, err := conversation.Receive()
+		if s.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			s.sendMu.Lock()
+			s.dropConversation(conversation)
+			s.sendMu.Unlock()
+			if !errors.Is(err, io.EOF) {
+				s.fail(err)
+			}
+			return
+		}
+		s.add(webLine{Speaker: "Eliza", Text: res.Sentence})
+	}
+}
+
+// dropConversation closes conversation and, if it's still the session's
+// stream, forgets it, so that the next sentence opens a new one. sendMu
+// must be held.
+func (s *webSession) dropConversation(conversation *connect.BidiStreamForClient[elizav1.ConverseRequest, elizav1.ConverseResponse]) {
+	if s.conversation == conversation {
+		s.conversation = nil
+	}
+	closeWebStream(conversation)
+}
+
+func (s *webSession) fail(err error) {
+	slog.Warn("web session call failed", errorAttrs(err)...)
+	s.add(webLine{Error: err.Error()})
+}
+
+func (s *webSession) add(line webLine) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = append(s.lines, line)
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// since returns the lines after the first n, a channel that's closed when
+// there are more, and whether the session has ended.
+func (s *webSession) since(n int) ([]webLine, <-chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n = max(0, min(n, len(s.lines)))
+	return s.lines[n:len(s.lines):len(s.lines)], s.changed, s.closed
+}
+
+// listen records a page listening to the session's events, until the
+// returned function is called.
+func (s *webSession) listen() func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners++
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.listeners--
+		if s.listeners == 0 {
+			s.idleSince = time.Now()
+		}
+	}
+}
+
+// idle returns how long, at now, the session has gone without a page
+// listening, or zero if one is.
+func (s *webSession) idle(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listeners > 0 {
+		return 0
+	}
+	return now.Sub(s.idleSince)
+}
+
+// close ends the session and its Converse stream.
+func (s *webSession) close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.mu.Unlock()
+
+	// Cancelling first unblocks any call in progress.
+	s.cancel()
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	if s.conversation != nil {
+		closeWebStream(s.conversation)
+	}
+}
+
+// closeWebStream closes both sides of a web session's Converse stream.
+func closeWebStream(conversation *connect.BidiStreamForClient[elizav1.ConverseRequest, elizav1.ConverseResponse]) {
+	if err := conversation.CloseRequest(); err != nil {
+		slog.Debug("closing web session's request stream", errorAttrs(err)...)
+	}
+	if err := conversation.CloseResponse(); err != nil {
+		slog.Debug("closing web session's response stream", errorAttrs(err)...)
+	}
+}
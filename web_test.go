@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+// webEvents reads the lines sent as server-sent events.
+type webEvents struct {
+	t       *testing.T
+	scanner *bufio.Scanner
+}
+
+func (e webEvents) next() webLine {
+	e.t.Helper()
+	for e.scanner.Scan() {
+		data, ok := strings.CutPrefix(e.scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var line webLine
+		attest.Ok(e.t, json.Unmarshal([]byte(data), &line), attest.Fatal())
+		return line
+	}
+	e.t.Fatalf("events ended: %v", e.scanner.Err())
+	return webLine{}
+}
+
+func postJSON(t *testing.T, url, body string) *http.Response {
+	t.Helper()
+	res, err := http.Post(url, "application/json", strings.NewReader(body))
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+func TestWebConversation(t *testing.T) {
+	t.Parallel()
+
+	web := newWebServer(startLocalServer(t))
+	server := httptest.NewServer(web.handler())
+	t.Cleanup(server.Close)
+	t.Cleanup(web.closeAll)
+
+	res, err := http.Get(server.URL)
+	attest.Ok(t, err, attest.Fatal())
+	page, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	attest.Ok(t, err)
+	attest.Subsequence(t, string(page), "<title>ELIZA</title>")
+
+	res = postJSON(t, server.URL+"/api/sessions", `{"name": ""}`)
+	attest.Equal(t, res.StatusCode, http.StatusBadRequest)
+	res = postJSON(t, server.URL+"/api/sessions", `{"name": "Charlie"}`)
+	attest.Equal(t, res.StatusCode, http.StatusCreated)
+	var created struct{ ID string }
+	attest.Ok(t, json.NewDecoder(res.Body).Decode(&created), attest.Fatal())
+	session := server.URL + "/api/sessions/" + created.ID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, session+"/events", nil)
+	attest.Ok(t, err, attest.Fatal())
+	stream, err := http.DefaultClient.Do(req)
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() { stream.Body.Close() })
+	attest.Equal(t, stream.Header.Get("Content-Type"), "text/event-stream")
+	events := webEvents{t: t, scanner: bufio.NewScanner(stream.Body)}
+	for _, sentence := range introduction("Charlie") {
+		attest.Equal(t, events.next(), webLine{Speaker: "Eliza", Text: sentence})
+	}
+
+	// Both sentences go over the same stream, so the doctor remembers
+	// the first.
+	for _, exchange := range [][2]string{
+		{"My mother hates me", "Tell me more about your family."},
+		{"whatever", "Let's discuss further why your mother hates you."},
+	} {
+		res = postJSON(t, session+"/messages", `{"sentence": "`+exchange[0]+`"}`)
+		attest.Equal(t, res.StatusCode, http.StatusAccepted)
+		attest.Equal(t, events.next(), webLine{Speaker: "Charlie", Text: exchange[0]})
+		attest.Equal(t, events.next(), webLine{Speaker: "Eliza", Text: exchange[1]})
+	}
+
+	res, err = http.Get(session + "/transcript")
+	attest.Ok(t, err, attest.Fatal())
+	defer res.Body.Close()
+	attest.Subsequence(t, res.Header.Get("Content-Disposition"), "attachment")
+	var transcript webTranscript
+	attest.Ok(t, json.NewDecoder(res.Body).Decode(&transcript), attest.Fatal())
+	attest.Equal(t, transcript.Name, "Charlie")
+	attest.Equal(t, len(transcript.Lines), len(introduction("Charlie"))+4)
+	attest.Equal(t, transcript.Lines[len(transcript.Lines)-1].Text, "Let's discuss further why your mother hates you.")
+
+	req, err = http.NewRequest(http.MethodDelete, session, nil)
+	attest.Ok(t, err, attest.Fatal())
+	res, err = http.DefaultClient.Do(req)
+	attest.Ok(t, err, attest.Fatal())
+	res.Body.Close()
+	attest.Equal(t, res.StatusCode, http.StatusNoContent)
+	// Ending the session ends its events.
+	for events.scanner.Scan() {
+	}
+	res = postJSON(t, session+"/messages", `{"sentence": "hello?"}`)
+	attest.Equal(t, res.StatusCode, http.StatusNotFound)
+}
+
+func TestWebSessionsAreSeparate(t *testing.T) {
+	t.Parallel()
+
+	client, handler := startFakeServerWithHandler(t)
+	web := newWebServer(client)
+	server := httptest.NewServer(web.handler())
+	t.Cleanup(server.Close)
+	t.Cleanup(web.closeAll)
+
+	for _, name := range []string{"Alice", "Bob"} {
+		res := postJSON(t, server.URL+"/api/sessions", `{"name": "`+name+`"}`)
+		var created struct{ ID string }
+		attest.Ok(t, json.NewDecoder(res.Body).Decode(&created), attest.Fatal())
+		for range 2 {
+			res = postJSON(t, server.URL+"/api/sessions/"+created.ID+"/messages", `{"sentence": "hello"}`)
+			attest.Equal(t, res.StatusCode, http.StatusAccepted)
+		}
+	}
+	web.closeAll()
+	<-handler.converseDone
+	<-handler.converseDone
+	attest.Equal(t, handler.converseCalls.Load(), int32(2))
+}
+
+func TestWebStreamIsReopenedAfterFailing(t *testing.T) {
+	t.Parallel()
+
+	client, handler := startBusyServer(t)
+	web := newWebServer(client)
+	server := httptest.NewServer(web.handler())
+	t.Cleanup(server.Close)
+	t.Cleanup(web.closeAll)
+
+	res := postJSON(t, server.URL+"/api/sessions", `{"name": "Alice"}`)
+	var created struct{ ID string }
+	attest.Ok(t, json.NewDecoder(res.Body).Decode(&created), attest.Fatal())
+	session := server.URL + "/api/sessions/" + created.ID
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, session+"/events", nil)
+	attest.Ok(t, err, attest.Fatal())
+	stream, err := http.DefaultClient.Do(req)
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() { stream.Body.Close() })
+	events := webEvents{t: t, scanner: bufio.NewScanner(stream.Body)}
+	for range introduction("Alice") {
+		events.next()
+	}
+
+	handler.busy.Store(1)
+	postJSON(t, session+"/messages", `{"sentence": "hello"}`)
+	attest.Equal(t, events.next(), webLine{Speaker: "Alice", Text: "hello"})
+	attest.Subsequence(t, events.next().Error, "too many requests")
+
+	// The failed stream is dropped, and the next sentence opens another.
+	res = postJSON(t, session+"/messages", `{"sentence": "again"}`)
+	attest.Equal(t, res.StatusCode, http.StatusAccepted)
+	attest.Equal(t, events.next(), webLine{Speaker: "Alice", Text: "again"})
+	attest.Equal(t, events.next(), webLine{Speaker: "Eliza", Text: `I see. You said: "again". Tell me more.`})
+	attest.Equal(t, handler.converseCalls.Load(), int32(1))
+}
+
+func TestIdleWebSessionsExpire(t *testing.T) {
+	t.Parallel()
+
+	client, handler := startFakeServerWithHandler(t)
+	web := newWebServer(client)
+	server := httptest.NewServer(web.handler())
+	t.Cleanup(server.Close)
+	t.Cleanup(web.closeAll)
+
+	start := func(name string) string {
+		res := postJSON(t, server.URL+"/api/sessions", `{"name": "`+name+`"}`)
+		var created struct{ ID string }
+		attest.Ok(t, json.NewDecoder(res.Body).Decode(&created), attest.Fatal())
+		session := server.URL + "/api/sessions/" + created.ID
+		res = postJSON(t, session+"/messages", `{"sentence": "hello"}`)
+		attest.Equal(t, res.StatusCode, http.StatusAccepted)
+		return session
+	}
+	exists := func(session string) bool {
+		res, err := http.Get(session + "/transcript")
+		attest.Ok(t, err, attest.Fatal())
+		res.Body.Close()
+		return res.StatusCode == http.StatusOK
+	}
+
+	// Alice's page crashed without ending her session; Bob's is open.
+	alice, bob := start("Alice"), start("Bob")
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bob+"/events", nil)
+	attest.Ok(t, err, attest.Fatal())
+	stream, err := http.DefaultClient.Do(req)
+	attest.Ok(t, err, attest.Fatal())
+	t.Cleanup(func() { stream.Body.Close() })
+	webEvents{t: t, scanner: bufio.NewScanner(stream.Body)}.next()
+
+	web.expireIdleAt(time.Now())
+	attest.True(t, exists(alice))
+	web.expireIdleAt(time.Now().Add(web.idleTimeout + time.Second))
+	attest.False(t, exists(alice))
+	attest.True(t, exists(bob))
+	<-handler.converseDone
+
+	// Once Bob's page goes, so does his session.
+	cancel()
+	deadline := time.Now().Add(3 * time.Second)
+	for exists(bob) {
+		attest.True(t, time.Now().Before(deadline), attest.Fatal(), attest.Sprintf("Bob's session never expired"))
+		time.Sleep(10 * time.Millisecond)
+		web.expireIdleAt(time.Now().Add(web.idleTimeout + time.Second))
+	}
+	<-handler.converseDone
+}